@@ -49,6 +49,32 @@ Iterator example:
 		...
 	}
 
+And backward:
+
+	iter := sl.NewReverseIterator(nil)
+	for iter.Prev() {
+		item := iter.Item()
+		...
+	}
+
+Descending example:
+
+	sl.Put(skiplist.Reversed{Item: skiplist.Int(3)})
+	sl.Put(skiplist.Reversed{Item: skiplist.Int(9)})
+	sl.PopFirst().(skiplist.Reversed).Item // 9
+
+Duplicates
+
+Items equal to each other can live in the same skiplist. Put adds an item
+in front of its equals while PutAllowDup adds it behind them, Get and Delete
+always work on the first one of the equals.
+
+Lazy Deletion
+
+LazySkipList leaves deleted items linked as tombstones, skipped by the reads
+and revived by putting equal items again, and sweeps them by Compact or once
+there're too many, which saves relinking nodes on churns of the same keys.
+
 Complexity
 
 Operation Put/Get/Delete time complexity are all O(logN). And the space
@@ -56,16 +82,23 @@ complexity is O(NlogN).
 
 Goroutine Safety
 
-No. Lock granularity depends on the use case.
+No. Lock granularity depends on the use case. SafeSkipList wraps a
+SkipList with a single RWMutex for the simple cases, ConcurrentSkipList
+locks nodes one by one for write heavy cases.
 
 */
 package skiplist // import "github.com/hit9/skiplist"
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
 	"math/rand"
+	"sync"
 	"time"
+	"unsafe"
 )
 
 // Item is a single object in the skiplist.
@@ -76,11 +109,15 @@ type Item interface {
 	Less(than Item) bool
 }
 
-// equal tests whether the item equal the given argument.
-func equal(item, than Item) bool {
+// Equal tests whether the item equal the given argument, that is
+// !item.Less(than) && !than.Less(item), the same way the skiplist does.
+func Equal(item, than Item) bool {
 	return !item.Less(than) && !than.Less(item)
 }
 
+// equal is an alias of Equal.
+func equal(item, than Item) bool { return Equal(item, than) }
+
 // Int implements the Item interface for integers.
 type Int int
 
@@ -89,10 +126,69 @@ func (i Int) Less(j Item) bool {
 	return i < j.(Int)
 }
 
+// Float64 implements the Item interface for float64s.
+type Float64 float64
+
+// Less returns true if float64(a) < float64(b). NaN is treated as less than
+// any other number and equal to NaN, as sort.Float64Slice does, to keep the
+// order strict.
+func (f Float64) Less(g Item) bool {
+	x, y := float64(f), float64(g.(Float64))
+	return x < y || math.IsNaN(x) && !math.IsNaN(y)
+}
+
+// String implements the Item interface for strings.
+type String string
+
+// Less returns true if string(a) < string(b)
+func (s String) Less(t Item) bool {
+	return s < t.(String)
+}
+
+// Weighted is an Item with a weight, whose sums are maintained by the
+// skiplist for PrefixSum. The weight must not change while the item is in a
+// skiplist. Items not implementing Weighted weigh 0.
+type Weighted interface {
+	Item
+	Weight() float64
+}
+
+// weightOf returns the weight of an item, 0 if it's not Weighted.
+func weightOf(item Item) float64 {
+	if w, ok := item.(Weighted); ok {
+		return w.Weight()
+	}
+	return 0
+}
+
+// Reversed wraps an Item to invert its order, so that a skiplist of
+// Reversed items is in descending order of the wrapped items, and First and
+// PopFirst work on the greatest one.
+type Reversed struct {
+	Item Item
+}
+
+// Less returns true if the wrapped item is greater than the other's.
+func (r Reversed) Less(than Item) bool {
+	return than.(Reversed).Item.Less(r.Item)
+}
+
 // node is an internel node in the skiplist.
 type node struct {
-	item     Item
-	forwards []*node
+	item Item
+	// backward is the previous node at level 0, the head for the first
+	// node.
+	backward *node
+	levels   []nodeLevel
+}
+
+// nodeLevel is a forward link of a node.
+type nodeLevel struct {
+	forward *node
+	// span is the number of level-0 steps to forward, 0 if forward is nil.
+	span int
+	// weight is the total weight of the nodes stepped over by span.
+	weight float64
 }
 
 // SkipList is an implementation of skiplist.
@@ -101,42 +197,337 @@ type SkipList struct {
 	level    int
 	maxLevel int
 	head     *node
-	rand     *rand.Rand
+	rand     RandSource
 	buf      []*node
+	ranks    []int
+	wranks   []float64 // Weighted positions along with ranks.
+	cmp      func(a, b Item) int
+	factorP  float64
+	pools    []sync.Pool // Free nodes by level, nil for no pooling.
+	growAt   int         // Length to grow maxLevel at, 0 for never.
+	upsert   bool        // Put works as Replace.
+	strict   bool        // Check the order on put.
+	stable   bool        // Put adds behind the equal items.
+	keep     bool        // Keep the level on deletions.
+	indexes  []*SkipList // Secondary indexes kept in sync.
+	levelOf  func(item Item) int
+	bits     uint64      // Random bits buffered from a uint64Source.
+	nbits    int
+	// The last node at each level and their positions, kept by seekTail
+	// and pushBack, valid only if tailOK, which is reset on other changes.
+	tail        []*node
+	tailRanks   []int
+	tailWeights []float64
+	tailOK      bool
+}
+
+// Stats is a report on the skiplist layout.
+type Stats struct {
+	Length   int
+	Level    int
+	MaxLevel int
+	// PerLevel[i] is the number of nodes present at level i.
+	PerLevel []int
 }
 
 // Iterator is skiplist iterator.
 type Iterator struct {
-	sl *SkipList
-	n  *node
+	sl   *SkipList
+	n    *node
+	pos  int  // Position of n, the head is at 0.
+	stop Item // Next stops on items >= stop, nil for no bound.
+	// Prev stops on items < start, nil for no bound.
+	start Item
+	level int // Next and Prev go through the nodes reaching this level.
+}
+
+// RandSource is the source of randomness to get the rand level, which
+// *rand.Rand satisfies.
+type RandSource interface {
+	// Intn returns a number in [0, n).
+	Intn(n int) int
+}
+
+// WithStrictCheck makes the skiplist check on each put that the order is
+// strict between the item and its neighbors, and panic otherwise, e.g. for a
+// Less implemented with <=. Debug purpose.
+func WithStrictCheck() Option {
+	return func(sl *SkipList) { sl.strict = true }
+}
+
+// WithLevelOf makes the skiplist get the level of each item by levelOf,
+// e.g. from a hash of the item, instead of randomly, so that the layout is
+// decided by the items. The levels must be in [1, maxLevel], it panics
+// otherwise. The distribution of the levels should follow factorP still.
+func WithLevelOf(levelOf func(item Item) int) Option {
+	return func(sl *SkipList) { sl.levelOf = levelOf }
+}
+
+// uint64Source is a RandSource giving 64 random bits at a time, which
+// *rand.Rand satisfies. The bits are buffered by the skiplist to flip coins.
+type uint64Source interface {
+	RandSource
+	Uint64() uint64
+}
+
+// Option configures a SkipList on New.
+type Option func(sl *SkipList)
+
+// WithRandSource makes the skiplist get rand levels from src, e.g. for a
+// deterministic layout.
+func WithRandSource(src RandSource) Option {
+	return func(sl *SkipList) { sl.rand = src }
+}
+
+// WithUpdateOnEqual makes Put overwrite the first item equal to the given
+// item instead of adding one in front of it, as Replace does, so that the
+// skiplist works as an ordered map with a key-only Less.
+func WithUpdateOnEqual() Option {
+	return func(sl *SkipList) { sl.upsert = true }
+}
+
+// WithStableOrder makes Put add an item behind the items equal to it, as
+// PutAllowDup does, so that equal items are iterated in insertion order.
+// The order comes from the positions, no sequence numbers are kept.
+func WithStableOrder() Option {
+	return func(sl *SkipList) { sl.stable = true }
+}
+
+// WithKeepLevel makes deletions keep the level of the skiplist when its top
+// levels get empty, instead of lowering it. It saves lowering the level and
+// raising it again on churns, but searches go through the empty levels then,
+// until Compact, which costs more for small skiplists: BenchmarkLevelChurn
+// takes about 640ns per op with it and 560ns without. Measure before use.
+func WithKeepLevel() Option {
+	return func(sl *SkipList) { sl.keep = true }
 }
 
-// FactorP is the propability to get the rand level.
+// FactorP is the propability to get the rand level, the default for
+// skiplists created by New.
 var FactorP = 0.5
 
+// newNode creates a node of the given level. The levels of nodes up to
+// level 4, which are the most, are allocated along with the node.
 func newNode(level int, item Item) *node {
-	return &node{
-		item:     item,
-		forwards: make([]*node, level, level),
+	var n *node
+	switch level {
+	case 1:
+		x := &struct {
+			n node
+			l [1]nodeLevel
+		}{}
+		x.n.levels = x.l[:]
+		n = &x.n
+	case 2:
+		x := &struct {
+			n node
+			l [2]nodeLevel
+		}{}
+		x.n.levels = x.l[:]
+		n = &x.n
+	case 3:
+		x := &struct {
+			n node
+			l [3]nodeLevel
+		}{}
+		x.n.levels = x.l[:]
+		n = &x.n
+	case 4:
+		x := &struct {
+			n node
+			l [4]nodeLevel
+		}{}
+		x.n.levels = x.l[:]
+		n = &x.n
+	default:
+		n = &node{levels: make([]nodeLevel, level, level)}
 	}
+	n.item = item
+	return n
 }
 
-// New creates a new SkipList.
-func New(maxLevel int) *SkipList {
-	return NewWithRandSeed(maxLevel, time.Now().UnixNano())
+// New creates a new SkipList, with options if any.
+func New(maxLevel int, opts ...Option) *SkipList {
+	sl := NewWithRandSeed(maxLevel, time.Now().UnixNano())
+	for _, opt := range opts {
+		opt(sl)
+	}
+	return sl
 }
 
 // NewWithRandSeed creates a new SkipList with a given seed.
 func NewWithRandSeed(maxLevel int, seed int64) *SkipList {
+	return NewWithOptions(maxLevel, FactorP, seed)
+}
+
+// NewWithOptions creates a new SkipList with a given propability to get
+// the rand level, which must be in (0, 1), and a given seed.
+func NewWithOptions(maxLevel int, factorP float64, seed int64) *SkipList {
 	if maxLevel < 2 {
 		panic("skiplist: bad maxLevel")
 	}
+	if !(factorP > 0 && factorP < 1) {
+		panic("skiplist: bad factorP")
+	}
 	return &SkipList{
 		maxLevel: maxLevel,
 		head:     newNode(maxLevel, nil),
 		rand:     rand.New(rand.NewSource(seed)),
 		buf:      make([]*node, maxLevel, maxLevel),
+		ranks:    make([]int, maxLevel, maxLevel),
+		wranks:   make([]float64, maxLevel, maxLevel),
+		factorP:  factorP,
+	}
+}
+
+// NewWithPool creates a new SkipList reusing the nodes of deleted items,
+// which saves allocations for workloads putting and deleting items in
+// turn. An iterator must not be kept on a deleted item, as its node may be
+// reused.
+func NewWithPool(maxLevel int) *SkipList {
+	sl := New(maxLevel)
+	sl.pools = make([]sync.Pool, maxLevel)
+	return sl
+}
+
+// autoMaxLevel is the limit of maxLevel for NewAutoLevel.
+const autoMaxLevel = 64
+
+// NewAutoLevel creates a new SkipList starting with the given maxLevel,
+// which grows as the length crosses the powers of 1/FactorP.
+func NewAutoLevel(initialLevel int) *SkipList {
+	sl := New(initialLevel)
+	sl.updateGrowAt()
+	return sl
+}
+
+// updateGrowAt sets the length to grow maxLevel at, (1/factorP)^maxLevel.
+func (sl *SkipList) updateGrowAt() {
+	if sl.maxLevel >= autoMaxLevel {
+		sl.growAt = 0
+		return
+	}
+	sl.growAt = int(math.Pow(1/sl.factorP, float64(sl.maxLevel)))
+}
+
+// resize changes the maxLevel of the skiplist, which must not be less than
+// the level, nodes in the pools are dropped.
+func (sl *SkipList) resize(maxLevel int) {
+	levels := make([]nodeLevel, maxLevel, maxLevel)
+	copy(levels, sl.head.levels)
+	sl.head.levels = levels
+	sl.buf = make([]*node, maxLevel, maxLevel)
+	sl.ranks = make([]int, maxLevel, maxLevel)
+	sl.wranks = make([]float64, maxLevel, maxLevel)
+	if sl.pools != nil {
+		sl.pools = make([]sync.Pool, maxLevel)
+	}
+	sl.maxLevel = maxLevel
+	sl.tailOK = false
+}
+
+// allocNode returns a node of the given level for the item, from the pool
+// if possible.
+func (sl *SkipList) allocNode(level int, item Item) *node {
+	if sl.pools != nil {
+		if v := sl.pools[level-1].Get(); v != nil {
+			n := v.(*node)
+			n.item = item
+			return n
+		}
+	}
+	return newNode(level, item)
+}
+
+// freeNode puts an unlinked node back to the pool, if pooling.
+func (sl *SkipList) freeNode(n *node) {
+	if sl.pools == nil {
+		return
+	}
+	n.item, n.backward = nil, nil
+	for i := range n.levels {
+		n.levels[i] = nodeLevel{}
+	}
+	sl.pools[len(n.levels)-1].Put(n)
+}
+
+// newLike creates an empty SkipList with the same settings as sl, but a
+// given maxLevel and a new seed.
+func (sl *SkipList) newLike(maxLevel int) *SkipList {
+	c := NewWithOptions(maxLevel, sl.factorP, time.Now().UnixNano())
+	c.cmp = sl.cmp
+	c.upsert = sl.upsert
+	c.strict = sl.strict
+	c.stable = sl.stable
+	c.keep = sl.keep
+	c.levelOf = sl.levelOf
+	if _, ok := sl.rand.(*secureSource); ok { // Keep it unpredictable.
+		c.rand = &secureSource{off: secureBufSize}
+	}
+	if sl.pools != nil {
+		c.pools = make([]sync.Pool, maxLevel)
+	}
+	if sl.growAt != 0 {
+		c.updateGrowAt()
+	}
+	return c
+}
+
+// NewWithComparator creates a new SkipList ordering items by cmp instead
+// of Item.Less. The cmp should return a negative number if a < b, zero if
+// a == b and a positive number if a > b. A nil cmp falls back to Less.
+func NewWithComparator(maxLevel int, cmp func(a, b Item) int) *SkipList {
+	sl := New(maxLevel)
+	sl.cmp = cmp
+	return sl
+}
+
+// less tests whether a is less than b within this skiplist.
+func (sl *SkipList) less(a, b Item) bool {
+	if sl.cmp != nil {
+		return sl.cmp(a, b) < 0
+	}
+	return a.Less(b)
+}
+
+// equal tests whether a equals b within this skiplist.
+func (sl *SkipList) equal(a, b Item) bool {
+	if sl.cmp != nil {
+		return sl.cmp(a, b) == 0
+	}
+	return equal(a, b)
+}
+
+// BuildFromSorted creates a new SkipList from items in non-decreasing
+// order, linking the nodes in one pass instead of putting them one by one.
+// It panics if the items are not sorted. O(N)
+func BuildFromSorted(maxLevel int, items []Item) *SkipList {
+	sl := New(maxLevel)
+	sl.seekTail()
+	for i, item := range items {
+		if i > 0 && item.Less(items[i-1]) {
+			panic("skiplist: items not sorted")
+		}
+		sl.pushBack(newNode(sl.levelFor(item), item))
 	}
+	return sl
+}
+
+// Resize changes the maxLevel of the skiplist in place, e.g. to keep
+// searching fast when it's grown larger than expected. It fails if any node
+// is above the new maxLevel, or the new maxLevel is less than 2. O(maxLevel)
+func (sl *SkipList) Resize(maxLevel int) error {
+	if maxLevel < 2 {
+		return fmt.Errorf("skiplist: bad maxLevel %d", maxLevel)
+	}
+	if sl.level > maxLevel {
+		return fmt.Errorf("skiplist: level %d above maxLevel %d", sl.level, maxLevel)
+	}
+	sl.resize(maxLevel)
+	if sl.growAt != 0 {
+		sl.updateGrowAt()
+	}
+	return nil
 }
 
 // Len returns skiplist length.
@@ -148,11 +539,65 @@ func (sl *SkipList) Level() int { return sl.level }
 // MaxLevel returns skiplist maxLevel.
 func (sl *SkipList) MaxLevel() int { return sl.maxLevel }
 
+// levelFor returns the level of a new node for the item, by levelOf if set,
+// otherwise randomly.
+func (sl *SkipList) levelFor(item Item) int {
+	if sl.levelOf == nil {
+		return sl.randLevel()
+	}
+	level := sl.levelOf(item)
+	if level < 1 || level > sl.maxLevel {
+		panic(fmt.Sprintf("skiplist: bad level %d of %v", level, item))
+	}
+	return level
+}
+
 // randLevel returns a level between 1 and maxLevel.
 func (sl *SkipList) randLevel() int {
+	src, ok := sl.rand.(uint64Source)
+	if !ok {
+		level := 1
+		for sl.rand.Intn(0x10000) < int(sl.factorP*float64(0xffff)) {
+			level++
+		}
+		if level < sl.maxLevel {
+			return level
+		}
+		return sl.maxLevel
+	}
+	// Flip coins with the buffered bits, one bit each for factorP 0.5, or
+	// 16 bits each otherwise.
 	level := 1
-	for sl.rand.Int()&0xffff < int(FactorP*float64(0xffff)) {
-		level++
+	if sl.factorP == 0.5 {
+		for level < sl.maxLevel {
+			if sl.nbits == 0 {
+				sl.bits, sl.nbits = src.Uint64(), 64
+			}
+			ones := bits.TrailingZeros64(^sl.bits)
+			if ones >= sl.nbits {
+				level += sl.nbits
+				sl.nbits = 0
+				continue
+			}
+			level += ones
+			sl.bits >>= uint(ones + 1)
+			sl.nbits -= ones + 1
+			break
+		}
+	} else {
+		threshold := uint64(sl.factorP * float64(0xffff))
+		for level < sl.maxLevel {
+			if sl.nbits < 16 {
+				sl.bits, sl.nbits = src.Uint64(), 64
+			}
+			chunk := sl.bits & 0xffff
+			sl.bits >>= 16
+			sl.nbits -= 16
+			if chunk >= threshold {
+				break
+			}
+			level++
+		}
 	}
 	if level < sl.maxLevel {
 		return level
@@ -163,154 +608,1748 @@ func (sl *SkipList) randLevel() int {
 func (sl *SkipList) resetBuf() {
 	for i := 0; i < sl.maxLevel; i++ {
 		sl.buf[i] = nil
+		sl.ranks[i] = 0
+		sl.wranks[i] = 0
 	}
 }
 
-// Put adds an item to the skiplist. O(logN)
-func (sl *SkipList) Put(item Item) {
-	// Reuse update array and find the node.
+// search finds the rightmost node before the item at each level into
+// sl.buf, and their positions into sl.ranks, weighted ones into sl.wranks.
+// The head is at position 0. Items equal to the given item are passed over
+// if after is true. A nil item stops on the head.
+func (sl *SkipList) search(item Item, after bool) *node {
 	sl.resetBuf()
-	update := sl.buf
+	update, rank, wrank := sl.buf, sl.ranks, sl.wranks
 	n := sl.head
 	for i := sl.level - 1; i >= 0; i-- {
-		for n.forwards[i] != nil && n.forwards[i].item.Less(item) {
-			n = n.forwards[i]
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+			wrank[i] = wrank[i+1]
+		}
+		for item != nil && n.levels[i].forward != nil {
+			if after && sl.less(item, n.levels[i].forward.item) ||
+				!after && !sl.less(n.levels[i].forward.item, item) {
+				break
+			}
+			rank[i] += n.levels[i].span
+			wrank[i] += n.levels[i].weight
+			n = n.levels[i].forward
 		}
 		update[i] = n
 	}
+	return n
+}
+
+// Put adds an item to the skiplist, in front of the items equal to it, or
+// behind them with WithStableOrder, or overwrites the first of them with
+// WithUpdateOnEqual. O(logN)
+func (sl *SkipList) Put(item Item) {
+	if sl.upsert {
+		sl.Replace(item)
+		return
+	}
+	// Reuse update array and find the node.
+	sl.search(item, sl.stable)
+	sl.insertNode(item)
+}
+
+// PutNeighbors adds an item to the skiplist as Put does, and returns the
+// items right before and after it, nil where there's none. O(logN)
+func (sl *SkipList) PutNeighbors(item Item) (pred, succ Item) {
+	var n *node
+	if sl.upsert {
+		n = sl.search(item, false).levels[0].forward
+		if n != nil && sl.equal(n.item, item) {
+			sl.replaceNode(n, item)
+		} else {
+			n = sl.insertNode(item)
+		}
+	} else {
+		sl.search(item, sl.stable)
+		n = sl.insertNode(item)
+	}
+	if n.backward != sl.head {
+		pred = n.backward.item
+	}
+	if next := n.levels[0].forward; next != nil {
+		succ = next.item
+	}
+	return pred, succ
+}
+
+// PutBounded adds an item to the skiplist, and if the length exceeds the
+// capacity then, pops the last item and returns it, which may be the given
+// item itself. Keeping putting with the same capacity gives the smallest
+// items seen. O(logN)
+func (sl *SkipList) PutBounded(item Item, capacity int) (evicted Item) {
+	sl.Put(item)
+	if sl.length > capacity {
+		return sl.PopLast()
+	}
+	return nil
+}
+
+// PutAllowDup adds an item to the skiplist behind the items equal to it,
+// so that equal items are iterated in insertion order (FIFO). O(logN)
+func (sl *SkipList) PutAllowDup(item Item) {
+	sl.search(item, true)
+	sl.insertNode(item)
+}
+
+// PutAppend adds an item behind the last item, which is much cheaper than
+// Put for items loaded in order. The tail is kept between the calls, so
+// that it compares the item only with the last one, unless the skiplist is
+// changed otherwise. It fails if the item is less than the last one, and
+// overwrites the last one if they're equal WithUpdateOnEqual. O(1)
+// amortized for consecutive calls, O(logN) otherwise.
+func (sl *SkipList) PutAppend(item Item) error {
+	if !sl.tailOK {
+		sl.seekTail()
+	}
+	if last := sl.tail[0]; last != sl.head {
+		if sl.less(item, last.item) {
+			return fmt.Errorf("skiplist: item %v less than the last", item)
+		}
+		if sl.upsert && !sl.less(last.item, item) {
+			sl.Replace(item)
+			return nil
+		}
+	}
+	if sl.strict {
+		sl.checkStrict(item, sl.tail[0], nil)
+	}
+	sl.pushBack(sl.allocNode(sl.levelFor(item), item))
+	sl.indexPut(item)
+	if sl.growAt != 0 && sl.length > sl.growAt {
+		sl.resize(sl.maxLevel + 1)
+		sl.updateGrowAt()
+	}
+	return nil
+}
+
+// Replace overwrites the first item equal to the given item and returns the
+// old one, or adds the item if there's no such one. O(logN)
+func (sl *SkipList) Replace(item Item) (old Item, replaced bool) {
+	n := sl.search(item, false).levels[0].forward
+	if n != nil && sl.equal(n.item, item) {
+		return sl.replaceNode(n, item), true
+	}
+	sl.insertNode(item)
+	return nil, false
+}
+
+// replaceNode overwrites the item of node n, which is right after the nodes
+// found by the last search, and returns the old one.
+func (sl *SkipList) replaceNode(n *node, item Item) Item {
+	old := n.item
+	n.item = item
+	sl.addWeight(weightOf(item) - weightOf(old))
+	sl.indexDelete(old)
+	sl.indexPut(item)
+	return old
+}
+
+// GetOrPut returns the first item equal to the given item with loaded
+// true, or adds the item and returns it with loaded false if there's no
+// such one. O(logN)
+func (sl *SkipList) GetOrPut(item Item) (actual Item, loaded bool) {
+	n := sl.search(item, false).levels[0].forward
+	if n != nil && sl.equal(n.item, item) {
+		return n.item, true
+	}
+	sl.insertNode(item)
+	return item, false
+}
+
+// PutIfAbsent adds an item to the skiplist and returns true if there's no
+// item equal to it, otherwise does nothing and returns false. O(logN)
+func (sl *SkipList) PutIfAbsent(item Item) bool {
+	_, loaded := sl.GetOrPut(item)
+	return !loaded
+}
+
+// PutFromChannel puts the items received from ch until it's closed, or ctx
+// is done, which returns ctx.Err() then. O(MlogN)
+func (sl *SkipList) PutFromChannel(ctx context.Context, ch <-chan Item) error {
+	for {
+		select {
+		case item, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			sl.Put(item)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// insertNode links a new node for the item into the skiplist right after
+// the nodes found by the last search.
+func (sl *SkipList) insertNode(item Item) *node {
+	update, rank, wrank := sl.buf, sl.ranks, sl.wranks
+	if sl.strict {
+		prev := update[0]
+		if prev == nil { // Empty, without any level yet.
+			prev = sl.head
+		}
+		sl.checkStrict(item, prev, prev.levels[0].forward)
+	}
+	w := weightOf(item)
 	// New level.
-	level := sl.randLevel()
+	level := sl.levelFor(item)
 	if level > sl.level {
 		for i := sl.level; i < level; i++ {
 			update[i] = sl.head
+			rank[i], wrank[i] = 0, 0
+			sl.head.levels[i] = nodeLevel{}
 		}
 		sl.level = level
 	}
 	// Add node.
-	n = newNode(level, item)
+	n := sl.allocNode(level, item)
 	for i := 0; i < level; i++ {
-		n.forwards[i] = update[i].forwards[i]
-		update[i].forwards[i] = n
+		if update[i].levels[i].forward != nil {
+			n.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+			n.levels[i].weight = update[i].levels[i].weight - (wrank[0] - wrank[i])
+		}
+		n.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = n
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+		update[i].levels[i].weight = wrank[0] - wrank[i] + w
+	}
+	n.backward = update[0]
+	if n.levels[0].forward != nil {
+		n.levels[0].forward.backward = n
+	}
+	sl.tailOK = false
+	sl.indexPut(item)
+	// Nodes above jump over the new node.
+	for i := level; i < sl.level; i++ {
+		if update[i].levels[i].forward != nil {
+			update[i].levels[i].span++
+			update[i].levels[i].weight += w
+		}
 	}
 	sl.length++
+	if sl.growAt != 0 && sl.length > sl.growAt {
+		sl.resize(sl.maxLevel + 1)
+		sl.updateGrowAt()
+	}
+	return n
 }
 
-// Get an item from the skiplist, nil on not found. O(logN)
-func (sl *SkipList) Get(item Item) Item {
-	n := sl.head
-	for i := sl.level - 1; i >= 0; i-- {
-		for n.forwards[i] != nil && n.forwards[i].item.Less(item) {
-			n = n.forwards[i]
-		}
+// checkStrict panics if the order between the item and itself or the nodes
+// prev and next is not strict, the head and nil are skipped.
+func (sl *SkipList) checkStrict(item Item, prev, next *node) {
+	if sl.less(item, item) {
+		panic(fmt.Sprintf("skiplist: Less is not strict, %v < %v", item, item))
 	}
-	n = n.forwards[0]
-	if n != nil && equal(n.item, item) {
-		return n.item
+	for _, x := range []*node{prev, next} {
+		if x != nil && x != sl.head && sl.less(item, x.item) && sl.less(x.item, item) {
+			panic(fmt.Sprintf("skiplist: Less is not strict, %v < %v < %v", item, x.item, item))
+		}
 	}
-	return nil
 }
 
-// Has tests whether skiplist contains an item. O(logN)
-func (sl *SkipList) Has(item Item) bool { return sl.Get(item) != nil }
-
-// Delete an item from skiplist and return it, nil on not found. O(logN)
-func (sl *SkipList) Delete(item Item) Item {
-	// Find node.
+// searchRank finds the rightmost node before position pos at each level
+// into sl.buf, and their positions into sl.ranks.
+func (sl *SkipList) searchRank(pos int) *node {
 	sl.resetBuf()
-	update := sl.buf
-	head := sl.head
-	n := head
+	update, rank := sl.buf, sl.ranks
+	n := sl.head
 	for i := sl.level - 1; i >= 0; i-- {
-		for n.forwards[i] != nil && n.forwards[i].item.Less(item) {
-			n = n.forwards[i]
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for n.levels[i].forward != nil && rank[i]+n.levels[i].span < pos {
+			rank[i] += n.levels[i].span
+			n = n.levels[i].forward
 		}
 		update[i] = n
 	}
-	n = n.forwards[0]
-	if n == nil || !equal(n.item, item) {
-		return nil
+	return n
+}
+
+// seekTail finds the last node at each level into sl.tail, and their
+// positions into sl.tailRanks, weighted ones into sl.tailWeights, the levels
+// above sl.level get the head.
+func (sl *SkipList) seekTail() {
+	if len(sl.tail) != sl.maxLevel {
+		sl.tail = make([]*node, sl.maxLevel)
+		sl.tailRanks = make([]int, sl.maxLevel)
+		sl.tailWeights = make([]float64, sl.maxLevel)
 	}
-	// Delete
-	for i := 0; i < sl.level; i++ {
-		if update[i].forwards[i] == n {
-			update[i].forwards[i] = n.forwards[i]
+	n := sl.head
+	pos, wpos := 0, 0.0
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		for i < sl.level && n.levels[i].forward != nil {
+			pos += n.levels[i].span
+			wpos += n.levels[i].weight
+			n = n.levels[i].forward
 		}
+		sl.tail[i], sl.tailRanks[i], sl.tailWeights[i] = n, pos, wpos
 	}
-	// Decrease level if need.
-	for sl.level > 1 && head.forwards[sl.level-1] == nil {
-		sl.level--
+	sl.tailOK = true
+}
+
+// pushBack links node n behind the last node, sl.tail and sl.tailRanks
+// should be prepared by seekTail, and are kept pointing to the tail.
+func (sl *SkipList) pushBack(n *node) {
+	pos, wpos := sl.length+1, sl.tailWeights[0]+weightOf(n.item)
+	n.backward = sl.tail[0]
+	for i := range n.levels {
+		sl.tail[i].levels[i] = nodeLevel{
+			forward: n,
+			span:    pos - sl.tailRanks[i],
+			weight:  wpos - sl.tailWeights[i],
+		}
+		sl.tail[i], sl.tailRanks[i], sl.tailWeights[i] = n, pos, wpos
 	}
-	sl.length--
-	return n.item
+	if len(n.levels) > sl.level {
+		sl.level = len(n.levels)
+	}
+	sl.length++
 }
 
-// First returns the first item, nil on not found. O(1)
-func (sl *SkipList) First() Item {
-	if sl.length == 0 {
-		return nil
+// Get an item from the skiplist, nil on not found. If there are duplicates,
+// the first one of them is returned. O(logN)
+func (sl *SkipList) Get(item Item) Item {
+	n := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, item) {
+			n = n.levels[i].forward
+		}
+	}
+	n = n.levels[0].forward
+	if n != nil && sl.equal(n.item, item) {
+		return n.item
 	}
-	return sl.head.forwards[0].item
+	return nil
 }
 
-// PopFirst pops the first item and returns it, nil on empty. O(1)
-func (sl *SkipList) PopFirst() Item {
-	if sl.length == 0 {
+// Floor returns the greatest item <= the given item, nil on not found.
+// O(logN)
+func (sl *SkipList) Floor(item Item) Item {
+	n := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && !sl.less(item, n.levels[i].forward.item) {
+			n = n.levels[i].forward
+		}
+	}
+	if n == sl.head {
 		return nil
 	}
-	n := sl.head.forwards[0]
-	for i := sl.level - 1; i >= 0; i-- { // Release upward
-		if sl.head.forwards[i] == n {
-			sl.head.forwards[i] = n.forwards[i]
+	return n.item
+}
+
+// Ceil returns the smallest item >= the given item, nil on not found.
+// O(logN)
+func (sl *SkipList) Ceil(item Item) Item {
+	n := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, item) {
+			n = n.levels[i].forward
 		}
 	}
-	for sl.level > 1 && sl.head.forwards[sl.level-1] == nil {
-		sl.level--
+	if n = n.levels[0].forward; n != nil {
+		return n.item
 	}
-	sl.length--
-	return n.item
+	return nil
 }
 
-// Clear the skiplist.
-func (sl *SkipList) Clear() {
-	for sl.PopFirst() != nil {
+// Closest returns the one of Floor and Ceil of the given item which is
+// closer to it by dist, Floor on a tie, nil on empty. O(logN)
+func (sl *SkipList) Closest(item Item, dist func(a, b Item) float64) Item {
+	floor, ceil := sl.Floor(item), sl.Ceil(item)
+	if floor == nil {
+		return ceil
 	}
+	if ceil == nil || dist(floor, item) <= dist(ceil, item) {
+		return floor
+	}
+	return ceil
 }
 
-// NewIterator returns a new iterator on this skiplist with an item start,
-// if the start is nil, iterator starts on head.
-// Filter items >= start.
-func (sl *SkipList) NewIterator(start Item) *Iterator {
+// Neighbors returns the greatest item < the given item as pred, and the
+// smallest item > the given item as succ, either nil on not found. Both are
+// found in one search. O(logN)
+func (sl *SkipList) Neighbors(item Item) (pred, succ Item) {
 	n := sl.head
-	if start != nil {
-		for i := sl.level - 1; i >= 0; i-- {
-			for n.forwards[i] != nil && n.forwards[i].item.Less(start) {
-				n = n.forwards[i]
-			}
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, item) {
+			n = n.levels[i].forward
 		}
 	}
-	return &Iterator{sl: sl, n: n}
+	if n != sl.head {
+		pred = n.item
+	}
+	// Pass over the items equal to the given one.
+	for n = n.levels[0].forward; n != nil && !sl.less(item, n.item); {
+		n = n.levels[0].forward
+	}
+	if n != nil {
+		succ = n.item
+	}
+	return pred, succ
 }
 
-// Next seeks iterator next, returns false on end.
-func (iter *Iterator) Next() bool {
-	iter.n = iter.n.forwards[0]
-	return iter.n != nil
+// Has tests whether skiplist contains an item. O(logN)
+func (sl *SkipList) Has(item Item) bool { return sl.Get(item) != nil }
+
+// Delete an item from skiplist and return it, nil on not found. If there
+// are duplicates, only the first one of them is deleted. O(logN)
+func (sl *SkipList) Delete(item Item) Item {
+	// Find node.
+	n := sl.search(item, false).levels[0].forward
+	if n == nil || !sl.equal(n.item, item) {
+		return nil
+	}
+	return sl.deleteNode(n, sl.buf)
 }
 
-// Item returns current item on the iterator.
-func (iter *Iterator) Item() Item {
-	return iter.n.item
+// DeleteOk deletes an item from skiplist as Delete does, and reports
+// whether it's found. O(logN)
+func (sl *SkipList) DeleteOk(item Item) (Item, bool) {
+	deleted := sl.Delete(item)
+	return deleted, deleted != nil
 }
 
-// Print the skiplist, debug purpose.
-func (sl *SkipList) Print(w io.Writer) {
+// UpdateKey moves the first item equal to old to the position of the new
+// item, as Delete(old) and Put(new) do, and returns false if old is not
+// found. The node is kept if the new item stays in place, that is in front
+// of the items equal to it, or behind them WithStableOrder. With
+// WithUpdateOnEqual, the item equal to new is overwritten instead. O(logN)
+func (sl *SkipList) UpdateKey(old, new Item) bool {
+	n := sl.search(old, false).levels[0].forward
+	if n == nil || !sl.equal(n.item, old) {
+		return false
+	}
+	// Whether the new item goes between the neighbors.
+	prev, next := n.backward, n.levels[0].forward
+	var inPlace bool
+	switch {
+	case sl.upsert:
+		inPlace = (prev == sl.head || sl.less(prev.item, new)) &&
+			(next == nil || sl.less(new, next.item))
+	case sl.stable:
+		inPlace = (prev == sl.head || !sl.less(new, prev.item)) &&
+			(next == nil || sl.less(new, next.item))
+	default:
+		inPlace = (prev == sl.head || sl.less(prev.item, new)) &&
+			(next == nil || !sl.less(next.item, new))
+	}
+	if inPlace {
+		sl.replaceNode(n, new)
+		return true
+	}
+	sl.deleteNode(n, sl.buf)
+	if sl.upsert {
+		sl.Replace(new)
+		return true
+	}
+	sl.search(new, sl.stable)
+	sl.insertNode(new)
+	return true
+}
+
+// deleteNode unlinks node n from the skiplist and returns its item,
+// update[i] should be the rightmost node before n at level i.
+func (sl *SkipList) deleteNode(n *node, update []*node) Item {
+	w := weightOf(n.item)
+	// Delete
 	for i := 0; i < sl.level; i++ {
-		n := sl.head.forwards[i]
-		fmt.Fprintf(w, "Level[%d]: ", i)
-		for n != nil {
+		if update[i].levels[i].forward == n {
+			if n.levels[i].forward != nil {
+				update[i].levels[i].span += n.levels[i].span - 1
+				update[i].levels[i].weight += n.levels[i].weight - w
+			} else {
+				update[i].levels[i].span = 0
+				update[i].levels[i].weight = 0
+			}
+			update[i].levels[i].forward = n.levels[i].forward
+		} else if update[i].levels[i].forward != nil {
+			update[i].levels[i].span--
+			update[i].levels[i].weight -= w
+		}
+	}
+	if n.levels[0].forward != nil {
+		n.levels[0].forward.backward = n.backward
+	}
+	sl.shrinkLevel()
+	sl.length--
+	sl.tailOK = false
+	item := n.item
+	sl.indexDelete(item)
+	sl.freeNode(n)
+	return item
+}
+
+// addWeight adds delta to the weights stepped over the node right after the
+// nodes found by the last search, for its item changed in place.
+func (sl *SkipList) addWeight(delta float64) {
+	if delta == 0 {
+		return
+	}
+	sl.tailOK = false
+	for i := 0; i < sl.level; i++ {
+		if sl.buf[i].levels[i].forward != nil {
+			sl.buf[i].levels[i].weight += delta
+		}
+	}
+}
+
+// First returns the first item, nil on not found. O(1)
+func (sl *SkipList) First() Item {
+	if sl.length == 0 {
+		return nil
+	}
+	return sl.head.levels[0].forward.item
+}
+
+// FirstUnique returns the first item, and whether it's less than the second
+// one, that is no other item is equal to it. It returns nil and false on
+// empty. O(1)
+func (sl *SkipList) FirstUnique() (item Item, unique bool) {
+	if sl.length == 0 {
+		return nil, false
+	}
+	n := sl.head.levels[0].forward
+	next := n.levels[0].forward
+	return n.item, next == nil || sl.less(n.item, next.item)
+}
+
+// Last returns the last item, nil on not found. O(logN)
+func (sl *SkipList) Last() Item {
+	n := sl.lastNode()
+	if n == sl.head {
+		return nil
+	}
+	return n.item
+}
+
+// lastNode returns the last node, the head on empty.
+func (sl *SkipList) lastNode() *node {
+	n := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil {
+			n = n.levels[i].forward
+		}
+	}
+	return n
+}
+
+// Min returns the smallest item, nil on empty. Same as First. O(1)
+func (sl *SkipList) Min() Item { return sl.First() }
+
+// Max returns the greatest item, nil on empty. Same as Last. O(logN)
+func (sl *SkipList) Max() Item { return sl.Last() }
+
+// Rank returns the 0-based position of the first item equal to the given
+// item, -1 on not found. O(logN)
+func (sl *SkipList) Rank(item Item) int {
+	rank, n := sl.countLess(item)
+	if n != nil && sl.equal(n.item, item) {
+		return rank
+	}
+	return -1
+}
+
+// RankRange returns the 0-based positions [lo, hi) of the items equal to
+// the given item, so that hi-lo is the number of them, (-1, -1) on not
+// found. O(logN)
+func (sl *SkipList) RankRange(item Item) (lo, hi int) {
+	lo, n := sl.countLess(item)
+	if n == nil || !sl.equal(n.item, item) {
+		return -1, -1
+	}
+	sl.search(item, true)
+	return lo, sl.ranks[0]
+}
+
+// countLess returns the number of items less than the given item, and the
+// first node not less than it.
+func (sl *SkipList) countLess(item Item) (int, *node) {
+	n := sl.head
+	rank := 0
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, item) {
+			rank += n.levels[i].span
+			n = n.levels[i].forward
+		}
+	}
+	return rank, n.levels[0].forward
+}
+
+// PrefixSum returns the total weight of the items < the given item, see
+// Weighted. O(logN)
+func (sl *SkipList) PrefixSum(item Item) float64 {
+	n := sl.head
+	sum := 0.0
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, item) {
+			sum += n.levels[i].weight
+			n = n.levels[i].forward
+		}
+	}
+	return sum
+}
+
+// CountRange returns the number of items >= start and < stop. A nil start
+// means from the first, a nil stop means to the last. O(logN)
+func (sl *SkipList) CountRange(start, stop Item) int {
+	lo, hi := 0, sl.length
+	if start != nil {
+		lo, _ = sl.countLess(start)
+	}
+	if stop != nil {
+		hi, _ = sl.countLess(stop)
+	}
+	if hi < lo {
+		return 0
+	}
+	return hi - lo
+}
+
+// GetByRank returns the item at the 0-based position k, nil if k is out of
+// range. O(logN)
+func (sl *SkipList) GetByRank(k int) Item {
+	if k < 0 || k >= sl.length {
+		return nil
+	}
+	n := sl.head
+	pos := 0
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && pos+n.levels[i].span <= k+1 {
+			pos += n.levels[i].span
+			n = n.levels[i].forward
+		}
+		if pos == k+1 {
+			break
+		}
+	}
+	return n.item
+}
+
+// At returns the item at the 0-based position i as GetByRank does, and a
+// negative i counts from the end, that is -1 for the last one. It returns
+// nil if i is out of range. O(logN)
+func (sl *SkipList) At(i int) Item {
+	if i < 0 {
+		i += sl.length
+	}
+	return sl.GetByRank(i)
+}
+
+// Quantile returns the item at the 0-based position floor(q*(Len()-1)), e.g.
+// the median for 0.5, nil if the skiplist is empty. It panics if q is not in
+// [0, 1]. O(logN)
+func (sl *SkipList) Quantile(q float64) Item {
+	if !(q >= 0 && q <= 1) {
+		panic(fmt.Sprintf("skiplist: bad quantile %v", q))
+	}
+	if sl.length == 0 {
+		return nil
+	}
+	return sl.GetByRank(int(math.Floor(q * float64(sl.length-1))))
+}
+
+// DeleteByRank deletes the item at the 0-based position k and returns it,
+// nil if k is out of range. Unlike Delete, it's exact with duplicates.
+// O(logN)
+func (sl *SkipList) DeleteByRank(k int) Item {
+	if k < 0 || k >= sl.length {
+		return nil
+	}
+	n := sl.searchRank(k + 1).levels[0].forward
+	return sl.deleteNode(n, sl.buf)
+}
+
+// AnyInRange tests whether any item is >= start and < stop. A nil start
+// means from the first, a nil stop means to the last. O(logN)
+func (sl *SkipList) AnyInRange(start, stop Item) bool {
+	n := sl.head
+	if start != nil {
+		for i := sl.level - 1; i >= 0; i-- {
+			for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, start) {
+				n = n.levels[i].forward
+			}
+		}
+	}
+	n = n.levels[0].forward
+	return n != nil && (stop == nil || sl.less(n.item, stop))
+}
+
+// PopFirst pops the first item and returns it, nil on empty. O(1)
+func (sl *SkipList) PopFirst() Item {
+	if sl.length == 0 {
+		return nil
+	}
+	n := sl.search(nil, false).levels[0].forward
+	return sl.deleteNode(n, sl.buf)
+}
+
+// PopFirstN pops the first n items and returns them in order, all items
+// if n >= Len(). The head is relinked once per level. O(n+logN)
+func (sl *SkipList) PopFirstN(n int) []Item {
+	if n > sl.length {
+		n = sl.length
+	}
+	if n <= 0 {
+		return nil
+	}
+	head := sl.head
+	first := head.levels[0].forward
+	popped := 0.0 // Weight of the popped items.
+	for i := 0; i < sl.level; i++ {
+		x, pos, wpos := head.levels[i].forward, head.levels[i].span, head.levels[i].weight
+		for x != nil && pos <= n {
+			pos += x.levels[i].span
+			wpos += x.levels[i].weight
+			x = x.levels[i].forward
+		}
+		if i == 0 && x != nil {
+			popped = wpos - weightOf(x.item)
+		}
+		if x != nil {
+			head.levels[i] = nodeLevel{forward: x, span: pos - n, weight: wpos - popped}
+		} else {
+			head.levels[i] = nodeLevel{}
+		}
+	}
+	if x := head.levels[0].forward; x != nil {
+		x.backward = head
+	}
+	sl.shrinkLevel()
+	sl.length -= n
+	sl.tailOK = false
+	items := make([]Item, n)
+	for i, x := 0, first; i < n; i++ {
+		next := x.levels[0].forward
+		items[i] = x.item
+		sl.indexDelete(x.item)
+		sl.freeNode(x)
+		x = next
+	}
+	return items
+}
+
+// PopLast pops the last item and returns it, nil on empty. O(logN)
+func (sl *SkipList) PopLast() Item {
+	if sl.length == 0 {
+		return nil
+	}
+	sl.resetBuf()
+	update := sl.buf
+	n := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		// Stop before the last node.
+		for n.levels[i].forward != nil && n.levels[i].forward.levels[0].forward != nil {
+			n = n.levels[i].forward
+		}
+		update[i] = n
+	}
+	return sl.deleteNode(n.levels[0].forward, update)
+}
+
+// DeleteMany deletes each of the given items as Delete does, and returns
+// the number of items deleted. For items in order, the search goes on from
+// where the last one ends rather than from head, in one pass. Otherwise
+// they are deleted one by one. O(KlogN)
+func (sl *SkipList) DeleteMany(items []Item) int {
+	count := 0
+	for i := 1; i < len(items); i++ {
+		if sl.less(items[i], items[i-1]) {
+			for _, item := range items {
+				if sl.Delete(item) != nil {
+					count++
+				}
+			}
+			return count
+		}
+	}
+	update := sl.buf
+	for i := range update {
+		update[i] = sl.head
+	}
+	for _, item := range items {
+		for i := sl.level - 1; i >= 0; i-- {
+			// Go on from the one above if it's further.
+			n := update[i]
+			if i+1 < sl.level {
+				if x := update[i+1]; x != sl.head && (n == sl.head || sl.less(n.item, x.item)) {
+					n = x
+				}
+			}
+			for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, item) {
+				n = n.levels[i].forward
+			}
+			update[i] = n
+		}
+		if n := update[0].levels[0].forward; n != nil && sl.equal(n.item, item) {
+			sl.deleteNode(n, update)
+			count++
+		}
+	}
+	return count
+}
+
+// DeleteRange deletes items >= start and < stop, and returns the number of
+// items deleted. A nil start means from the first, a nil stop means to the
+// last. O(logN+K)
+func (sl *SkipList) DeleteRange(start, stop Item) int {
+	n := sl.search(start, false).levels[0].forward
+	count := 0
+	for n != nil && (stop == nil || sl.less(n.item, stop)) {
+		next := n.levels[0].forward
+		sl.deleteNode(n, sl.buf)
+		n = next
+		count++
+	}
+	return count
+}
+
+// RetainFunc keeps the items for which keep returns true and deletes the
+// rest, and returns the number of items deleted. The kept nodes are relinked
+// in one pass. O(N)
+func (sl *SkipList) RetainFunc(keep func(item Item) bool) int {
+	first, level := sl.head.levels[0].forward, sl.level
+	for i := 0; i < level; i++ {
+		sl.head.levels[i] = nodeLevel{}
+	}
+	sl.length, sl.level = 0, 1
+	sl.seekTail()
+	count := 0
+	for n := first; n != nil; {
+		next := n.levels[0].forward
+		if keep(n.item) {
+			sl.pushBack(n)
+		} else {
+			sl.indexDelete(n.item)
+			sl.freeNode(n)
+			count++
+		}
+		n = next
+	}
+	for i := 0; i < level; i++ {
+		sl.tail[i].levels[i] = nodeLevel{}
+	}
+	if sl.keep && sl.level < level {
+		sl.level = level
+	}
+	return count
+}
+
+// DeleteRangeFunc deletes items >= start and < stop for which pred returns
+// true, and returns the number of items deleted. A nil start means from the
+// first, a nil stop means to the last. O(logN+K)
+func (sl *SkipList) DeleteRangeFunc(start, stop Item, pred func(item Item) bool) int {
+	n := sl.search(start, false).levels[0].forward
+	count := 0
+	for n != nil && (stop == nil || sl.less(n.item, stop)) {
+		next := n.levels[0].forward
+		if pred(n.item) {
+			sl.deleteNode(n, sl.buf)
+			count++
+		} else {
+			// The kept one is the rightmost before the next.
+			for i := range n.levels {
+				sl.buf[i] = n
+			}
+		}
+		n = next
+	}
+	return count
+}
+
+// Truncate keeps the first k items and deletes the rest, the deleted nodes
+// are left to the GC. O(logN)
+func (sl *SkipList) Truncate(k int) {
+	if k < 0 {
+		k = 0
+	}
+	if k >= sl.length {
+		return
+	}
+	n := sl.searchRank(k + 1)
+	for x := n.levels[0].forward; x != nil && sl.indexes != nil; x = x.levels[0].forward {
+		sl.indexDelete(x.item)
+	}
+	for i := 0; i < sl.level; i++ {
+		sl.buf[i].levels[i] = nodeLevel{}
+	}
+	sl.length = k
+	sl.tailOK = false
+	sl.shrinkLevel()
+}
+
+// Split cuts the skiplist into two at the given key, left gets the items
+// < key and right gets the rest. The nodes are moved rather than copied,
+// the skiplist itself is returned as left. O(logN)
+func (sl *SkipList) Split(key Item) (left, right *SkipList) {
+	right = sl.newLike(sl.maxLevel)
+	sl.search(key, false)
+	k, wk := sl.ranks[0], sl.wranks[0]
+	for i := 0; i < sl.level; i++ {
+		x := sl.buf[i]
+		if x.levels[i].forward != nil {
+			right.head.levels[i] = nodeLevel{
+				forward: x.levels[i].forward,
+				span:    x.levels[i].span - (k - sl.ranks[i]),
+				weight:  x.levels[i].weight - (wk - sl.wranks[i]),
+			}
+		}
+		x.levels[i] = nodeLevel{}
+	}
+	if n := right.head.levels[0].forward; n != nil {
+		n.backward = right.head
+	}
+	right.level, right.length = sl.level, sl.length-k
+	sl.length = k
+	sl.tailOK = false
+	for x := right.head.levels[0].forward; x != nil && sl.indexes != nil; x = x.levels[0].forward {
+		sl.indexDelete(x.item)
+	}
+	sl.shrinkLevel()
+	right.shrinkLevel()
+	return sl, right
+}
+
+// Compact releases the memory kept for reuse, that is the free nodes in the
+// pools and the cached tail, and lowers the level to the highest one in
+// use. Deletions already lower the level unless WithKeepLevel, thus that
+// part matters only with the option. O(maxLevel)
+func (sl *SkipList) Compact() {
+	for sl.level > 1 && sl.head.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	if sl.pools != nil {
+		sl.pools = make([]sync.Pool, sl.maxLevel)
+	}
+	sl.tail, sl.tailRanks, sl.tailWeights, sl.tailOK = nil, nil, nil, false
+}
+
+// shrinkLevel lowers the level to the highest one in use, unless
+// WithKeepLevel.
+func (sl *SkipList) shrinkLevel() {
+	if sl.keep {
+		return
+	}
+	for sl.level > 1 && sl.head.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+}
+
+// Clear the skiplist, the nodes are left to the GC. O(maxLevel)
+func (sl *SkipList) Clear() {
+	for i := range sl.head.levels {
+		sl.head.levels[i] = nodeLevel{}
+	}
+	sl.resetBuf()
+	sl.level = 1
+	sl.length = 0
+	sl.tailOK = false
+	for _, idx := range sl.indexes {
+		idx.Clear()
+	}
+}
+
+// Concat joins b to the end of a and returns a, b is left empty. The nodes
+// of b are linked rather than copied. It panics if the items of a are not
+// all less than the items of b. O(logN), or O(level) right after PutAppend.
+func Concat(a, b *SkipList) *SkipList {
+	if b.length == 0 {
+		return a
+	}
+	// The levels of b in use, b may keep empty ones WithKeepLevel.
+	level := b.level
+	for level > 1 && b.head.levels[level-1].forward == nil {
+		level--
+	}
+	if level > a.maxLevel {
+		a.resize(level)
+		if a.growAt != 0 {
+			a.updateGrowAt()
+		}
+	}
+	if !a.tailOK {
+		a.seekTail()
+	}
+	first := b.head.levels[0].forward
+	if a.tail[0] != a.head && !a.less(a.tail[0].item, first.item) {
+		panic("skiplist: items not sorted")
+	}
+	for i := 0; i < level; i++ {
+		a.tail[i].levels[i] = nodeLevel{
+			forward: b.head.levels[i].forward,
+			span:    a.length - a.tailRanks[i] + b.head.levels[i].span,
+			weight:  a.tailWeights[0] - a.tailWeights[i] + b.head.levels[i].weight,
+		}
+	}
+	first.backward = a.tail[0]
+	if level > a.level {
+		a.level = level
+	}
+	a.length += b.length
+	a.tailOK = false
+	for x := first; x != nil && a.indexes != nil; x = x.levels[0].forward {
+		a.indexPut(x.item)
+	}
+	b.Clear()
+	return a
+}
+
+// AddSecondaryIndex returns a new skiplist of the items ordered by less,
+// which is kept in sync as items are put into and deleted from the
+// skiplist, so that the items can be looked up by another order. It costs
+// a node per item again. The index must be used read only. Items equal by
+// less are told apart by ==, thus they must be comparable. O(NlogN)
+func (sl *SkipList) AddSecondaryIndex(less func(a, b Item) bool) *SkipList {
+	idx := sl.newLike(sl.maxLevel)
+	idx.cmp = func(a, b Item) int {
+		if less(a, b) {
+			return -1
+		}
+		if less(b, a) {
+			return 1
+		}
+		return 0
+	}
+	idx.upsert = false
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		idx.Put(n.item)
+	}
+	sl.indexes = append(sl.indexes, idx)
+	return idx
+}
+
+// indexPut puts the item into each secondary index.
+func (sl *SkipList) indexPut(item Item) {
+	for _, idx := range sl.indexes {
+		idx.search(item, false)
+		idx.insertNode(item)
+	}
+}
+
+// indexDelete deletes the very item from each secondary index.
+func (sl *SkipList) indexDelete(item Item) {
+	for _, idx := range sl.indexes {
+		n := idx.search(item, false).levels[0].forward
+		for n != nil && n.item != item && idx.equal(n.item, item) {
+			for i := range n.levels {
+				idx.buf[i] = n
+			}
+			n = n.levels[0].forward
+		}
+		if n != nil && n.item == item {
+			idx.deleteNode(n, idx.buf)
+		}
+	}
+}
+
+// Clone returns a copy of the skiplist with all nodes copied, items are
+// shared. The copy has the same layout but a new rand seed. O(N)
+func (sl *SkipList) Clone() *SkipList {
+	c := sl.newLike(sl.maxLevel)
+	c.seekTail()
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		c.pushBack(newNode(len(n.levels), n.item))
+	}
+	c.level = sl.level
+	return c
+}
+
+// Equal tests whether the skiplist holds the same items in the same order
+// as other, regardless of the layout. O(N)
+func (sl *SkipList) Equal(other *SkipList) bool {
+	if sl.length != other.length {
+		return false
+	}
+	m, n := sl.head.levels[0].forward, other.head.levels[0].forward
+	for m != nil && n != nil {
+		if !sl.equal(m.item, n.item) {
+			return false
+		}
+		m, n = m.levels[0].forward, n.levels[0].forward
+	}
+	return m == nil && n == nil
+}
+
+// Merge puts every item of other into the skiplist, other is left
+// unchanged. O(MlogN)
+func (sl *SkipList) Merge(other *SkipList) {
+	if other == sl {
+		other = sl.Clone()
+	}
+	for n := other.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		sl.Put(n.item)
+	}
+}
+
+// newResult creates an empty skiplist to hold the result of a set operation
+// on a and b, with its tail ready for pushBack.
+func newResult(a, b *SkipList) *SkipList {
+	maxLevel := a.maxLevel
+	if b.maxLevel > maxLevel {
+		maxLevel = b.maxLevel
+	}
+	r := a.newLike(maxLevel)
+	r.seekTail()
+	return r
+}
+
+// Intersection returns a new skiplist of items both in a and b, ordered by
+// a. The inputs are left untouched. O(N+M)
+func Intersection(a, b *SkipList) *SkipList {
+	r := newResult(a, b)
+	m, n := a.head.levels[0].forward, b.head.levels[0].forward
+	for m != nil && n != nil {
+		switch {
+		case a.less(m.item, n.item):
+			m = m.levels[0].forward
+		case a.less(n.item, m.item):
+			n = n.levels[0].forward
+		default:
+			r.pushBack(newNode(r.levelFor(m.item), m.item))
+			m, n = m.levels[0].forward, n.levels[0].forward
+		}
+	}
+	return r
+}
+
+// IntersectCount returns the number of items both in a and b, the length
+// Intersection would have, without building it. O(N+M)
+func IntersectCount(a, b *SkipList) int {
+	count := 0
+	m, n := a.head.levels[0].forward, b.head.levels[0].forward
+	for m != nil && n != nil {
+		switch {
+		case a.less(m.item, n.item):
+			m = m.levels[0].forward
+		case a.less(n.item, m.item):
+			n = n.levels[0].forward
+		default:
+			count++
+			m, n = m.levels[0].forward, n.levels[0].forward
+		}
+	}
+	return count
+}
+
+// Union returns a new skiplist of items in a or b, ordered by a. For items
+// in both, the one from a is taken. The inputs are left untouched. O(N+M)
+func Union(a, b *SkipList) *SkipList {
+	r := newResult(a, b)
+	m, n := a.head.levels[0].forward, b.head.levels[0].forward
+	for m != nil || n != nil {
+		var item Item
+		switch {
+		case n == nil || m != nil && a.less(m.item, n.item):
+			item, m = m.item, m.levels[0].forward
+		case m == nil || a.less(n.item, m.item):
+			item, n = n.item, n.levels[0].forward
+		default:
+			item = m.item
+			m, n = m.levels[0].forward, n.levels[0].forward
+		}
+		r.pushBack(newNode(r.levelFor(item), item))
+	}
+	return r
+}
+
+// Difference returns a new skiplist of items in a but not in b, ordered by
+// a. The inputs are left untouched. O(N+M)
+func Difference(a, b *SkipList) *SkipList {
+	r := newResult(a, b)
+	m, n := a.head.levels[0].forward, b.head.levels[0].forward
+	for m != nil {
+		switch {
+		case n == nil || a.less(m.item, n.item):
+			r.pushBack(newNode(r.levelFor(m.item), m.item))
+			m = m.levels[0].forward
+		case a.less(n.item, m.item):
+			n = n.levels[0].forward
+		default:
+			m, n = m.levels[0].forward, n.levels[0].forward
+		}
+	}
+	return r
+}
+
+// NewIterator returns a new iterator on this skiplist with an item start,
+// if the start is nil, iterator starts on head.
+// Filter items >= start.
+func (sl *SkipList) NewIterator(start Item) *Iterator {
+	iter := &Iterator{sl: sl}
+	iter.Reset(start)
+	return iter
+}
+
+// NewRangeIterator returns a new iterator on this skiplist, filter items
+// >= start and < stop. A nil start starts on head, a nil stop means no upper
+// bound.
+func (sl *SkipList) NewRangeIterator(start, stop Item) *Iterator {
+	iter := sl.NewIterator(start)
+	iter.stop = stop
+	return iter
+}
+
+// NewRangeIteratorDesc returns a new iterator on this skiplist to walk
+// backward via Prev, filter items >= start and < stop, that is the items of
+// NewRangeIterator in descending order. A nil stop starts on the end, a nil
+// start means no lower bound. O(logN)
+func (sl *SkipList) NewRangeIteratorDesc(start, stop Item) *Iterator {
+	iter := sl.NewReverseIterator(stop)
+	iter.start = start
+	return iter
+}
+
+// NewLevelIterator returns a new iterator on this skiplist starting on head,
+// which goes only through the nodes reaching the given 0-based level, that
+// is about FactorP^level of the items, evenly spaced, e.g. to sample them.
+// Seek and Remaining work on all the items still, and Reset makes it a plain
+// iterator. It panics if the level is not in [0, MaxLevel()).
+func (sl *SkipList) NewLevelIterator(level int) *Iterator {
+	if level < 0 || level >= sl.maxLevel {
+		panic(fmt.Sprintf("skiplist: bad level %d", level))
+	}
+	return &Iterator{sl: sl, n: sl.head, level: level}
+}
+
+// NewReverseIterator returns a new iterator on this skiplist with an item
+// start to walk backward via Prev, if the start is nil, iterator starts on
+// the end. Filter items < start. O(logN)
+func (sl *SkipList) NewReverseIterator(start Item) *Iterator {
+	if start == nil {
+		return &Iterator{sl: sl}
+	}
+	rank, n := sl.countLess(start)
+	return &Iterator{sl: sl, n: n, pos: rank + 1}
+}
+
+// NewReverseIteratorFrom returns a new iterator on this skiplist with an
+// item start to walk backward via Prev, if the start is nil, iterator starts
+// on the end. Filter items <= start. O(logN)
+func (sl *SkipList) NewReverseIteratorFrom(start Item) *Iterator {
+	if start == nil {
+		return &Iterator{sl: sl}
+	}
+	n := sl.search(start, true)
+	return &Iterator{sl: sl, n: n.levels[0].forward, pos: sl.ranks[0] + 1}
+}
+
+// Next seeks iterator next, returns false on end. O(1)
+func (iter *Iterator) Next() bool {
+	if iter.n == nil {
+		return false
+	}
+	if iter.level == 0 {
+		iter.n = iter.n.levels[0].forward
+		iter.pos++
+	} else {
+		iter.nextTower()
+	}
+	if iter.n != nil && iter.stop != nil && !iter.sl.less(iter.n.item, iter.stop) {
+		iter.n = nil
+	}
+	return iter.n != nil
+}
+
+// nextTower moves the iterator to the next node reaching iter.level.
+func (iter *Iterator) nextTower() {
+	n, pos, i := iter.n, iter.pos, iter.level
+	if len(n.levels) > i {
+		pos += n.levels[i].span
+		n = n.levels[i].forward
+	} else { // Off the towers after Seek or Prev.
+		for {
+			n, pos = n.levels[0].forward, pos+1
+			if n == nil || len(n.levels) > i {
+				break
+			}
+		}
+	}
+	iter.n, iter.pos = n, pos
+}
+
+// Prev seeks iterator prev, returns false on begin. O(1), except the first
+// call on the end which takes O(logN) to find the last node.
+func (iter *Iterator) Prev() bool {
+	if iter.n == nil {
+		iter.n, iter.pos = iter.sl.lastNode(), iter.sl.length
+	} else if iter.n != iter.sl.head {
+		iter.n = iter.n.backward
+		iter.pos--
+	}
+	for iter.n != iter.sl.head && len(iter.n.levels) <= iter.level {
+		iter.n = iter.n.backward
+		iter.pos--
+	}
+	if iter.n != iter.sl.head && iter.start != nil && iter.sl.less(iter.n.item, iter.start) {
+		iter.n, iter.pos = iter.sl.head, 0
+	}
+	return iter.n != iter.sl.head
+}
+
+// Valid tests whether the iterator is on an item, that is Item can be
+// called, false on the begin or the end.
+func (iter *Iterator) Valid() bool {
+	return iter.n != nil && iter.n != iter.sl.head
+}
+
+// Item returns current item on the iterator.
+func (iter *Iterator) Item() Item {
+	return iter.n.item
+}
+
+// Level returns the level of the current node, that is the height of its
+// tower as Print shows, 0 on the begin or the end.
+func (iter *Iterator) Level() int {
+	if iter.n == nil || iter.n == iter.sl.head {
+		return 0
+	}
+	return len(iter.n.levels)
+}
+
+// Remove deletes the current item from the skiplist and moves the iterator
+// back to the previous one, so that the following Next goes to the next
+// item. The node is located by its position, thus it's safe with
+// duplicates. It does nothing on the begin or the end. O(logN)
+func (iter *Iterator) Remove() {
+	sl, n := iter.sl, iter.n
+	if n == nil || n == sl.head {
+		return
+	}
+	sl.searchRank(iter.pos)
+	iter.n, iter.pos = n.backward, iter.pos-1
+	sl.deleteNode(n, sl.buf)
+}
+
+// Reset moves the iterator back as if it's just returned by NewIterator
+// with the given start, so that it can be reused without allocation. The
+// bounds and the level of other constructors are dropped.
+func (iter *Iterator) Reset(start Item) {
+	iter.n, iter.pos, iter.stop, iter.start, iter.level = iter.sl.head, 0, nil, nil, 0
+	if start != nil {
+		iter.Seek(start)
+	}
+}
+
+// Seek moves the iterator, so that the following Next goes to the first
+// item >= the given item. It searches onward from the current node, which
+// is O(logD) with D the distance, but restarts from head if the given item
+// is not after the current one.
+func (iter *Iterator) Seek(item Item) {
+	sl, n, pos := iter.sl, iter.n, iter.pos
+	if n == nil || n != sl.head && !sl.less(n.item, item) {
+		n, pos = sl.head, 0
+	}
+	// Climb up the towers.
+	top := len(n.levels)
+	for top < sl.level {
+		x := n.levels[top-1].forward
+		if x == nil || !sl.less(x.item, item) {
+			break
+		}
+		pos += n.levels[top-1].span
+		n, top = x, len(x.levels)
+	}
+	if top > sl.level {
+		top = sl.level
+	}
+	// Then go down as usual.
+	for i := top - 1; i >= 0; i-- {
+		for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, item) {
+			pos += n.levels[i].span
+			n = n.levels[i].forward
+		}
+	}
+	iter.n, iter.pos = n, pos
+}
+
+// Remaining returns the number of items after the current one, up to the
+// stop bound if any, which the following Next calls go through, except for
+// NewLevelIterator, which skips the lower ones. O(1), or O(logN) with a stop
+// bound.
+func (iter *Iterator) Remaining() int {
+	if iter.n == nil {
+		return 0
+	}
+	end := iter.sl.length
+	if iter.stop != nil {
+		end, _ = iter.sl.countLess(iter.stop)
+	}
+	if end < iter.pos {
+		return 0
+	}
+	return end - iter.pos
+}
+
+// Values returns all items in order. O(N)
+func (sl *SkipList) Values() []Item {
+	items := make([]Item, 0, sl.length)
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		items = append(items, n.item)
+	}
+	return items
+}
+
+// GetN returns up to n items >= start in order, fewer if there're not
+// enough. If the start is nil, it starts on head. O(logN+n)
+func (sl *SkipList) GetN(start Item, n int) []Item {
+	if n > sl.length {
+		n = sl.length
+	}
+	if n <= 0 {
+		return nil
+	}
+	return sl.GetNInto(start, make([]Item, 0, n))
+}
+
+// GetNInto appends items >= start in order to dst until it's full, that is
+// up to cap(dst)-len(dst) items, and returns it, so that a slice can be
+// reused without allocations. If the start is nil, it starts on head.
+// O(logN+n)
+func (sl *SkipList) GetNInto(start Item, dst []Item) []Item {
+	if len(dst) == cap(dst) {
+		return dst
+	}
+	x := sl.search(start, false).levels[0].forward
+	for ; x != nil && len(dst) < cap(dst); x = x.levels[0].forward {
+		dst = append(dst, x.item)
+	}
+	return dst
+}
+
+// Peek returns up to k smallest items in order without popping them, as
+// PopFirstN does otherwise. Same as GetN(nil, k). O(k)
+func (sl *SkipList) Peek(k int) []Item { return sl.GetN(nil, k) }
+
+// Sample returns up to head smallest items and up to tail greatest items in
+// order, the two may overlap if the skiplist is short. O(logN+head+tail)
+func (sl *SkipList) Sample(head, tail int) (first, last []Item) {
+	first = sl.GetN(nil, head)
+	if tail > sl.length {
+		tail = sl.length
+	}
+	if tail <= 0 {
+		return first, nil
+	}
+	last = make([]Item, 0, tail)
+	n := sl.searchRank(sl.length - tail + 1).levels[0].forward
+	for ; n != nil; n = n.levels[0].forward {
+		last = append(last, n.item)
+	}
+	return first, last
+}
+
+// Snapshot returns a point-in-time copy of all items in order, which is
+// kept stable while the skiplist is changed, e.g. to put or delete items
+// during the traversal. Same as Values. O(N)
+func (sl *SkipList) Snapshot() []Item { return sl.Values() }
+
+// IntSlice returns all items in order as ints, it fails if any item is not
+// an Int. O(N)
+func (sl *SkipList) IntSlice() ([]int, error) {
+	values := make([]int, 0, sl.length)
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		i, ok := n.item.(Int)
+		if !ok {
+			return nil, fmt.Errorf("skiplist: item %v is not an Int", n.item)
+		}
+		values = append(values, int(i))
+	}
+	return values, nil
+}
+
+// Stats returns a report on the skiplist layout. O(N)
+func (sl *SkipList) Stats() Stats {
+	perLevel := make([]int, sl.level)
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		for i := range n.levels {
+			perLevel[i]++
+		}
+	}
+	return Stats{
+		Length:   sl.length,
+		Level:    sl.level,
+		MaxLevel: sl.maxLevel,
+		PerLevel: perLevel,
+	}
+}
+
+// LevelLen returns the number of nodes at the given level, 0 if the level
+// is out of range. It walks the level only, which is lighter than Stats.
+// O(N*factorP^level)
+func (sl *SkipList) LevelLen(level int) int {
+	if level < 0 || level >= sl.level {
+		return 0
+	}
+	count := 0
+	for n := sl.head.levels[level].forward; n != nil; n = n.levels[level].forward {
+		count++
+	}
+	return count
+}
+
+// MaxGap returns the largest number of consecutive nodes at level 0 only,
+// which are walked one by one in a search. A large gap tells a degenerate
+// region. O(N*factorP)
+func (sl *SkipList) MaxGap() int {
+	if sl.level < 2 {
+		return sl.length
+	}
+	gap, pos := 0, 0
+	for n := sl.head; n.levels[1].forward != nil; n = n.levels[1].forward {
+		if n.levels[1].span-1 > gap {
+			gap = n.levels[1].span - 1
+		}
+		pos += n.levels[1].span
+	}
+	if sl.length-pos > gap {
+		gap = sl.length - pos
+	}
+	return gap
+}
+
+// MeanLevel returns the average level of the nodes, 0 on empty, which
+// should be close to 1/(1-factorP). O(N)
+func (sl *SkipList) MeanLevel() float64 {
+	if sl.length == 0 {
+		return 0
+	}
+	sum := 0
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		sum += len(n.levels)
+	}
+	return float64(sum) / float64(sl.length)
+}
+
+// rangeCheckEvery is the number of items RangeContext visits between the
+// checks of the context.
+const rangeCheckEvery = 256
+
+// RangeContext calls f on each item >= start and < stop in order as
+// RangeFunc does, until f returns an error, which is returned then. The ctx
+// is checked every rangeCheckEvery items, ctx.Err() is returned once it's
+// done.
+func (sl *SkipList) RangeContext(ctx context.Context, start, stop Item, f func(Item) error) error {
+	var err error
+	count := 0
+	sl.RangeFunc(start, stop, func(item Item) bool {
+		if count%rangeCheckEvery == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		count++
+		err = f(item)
+		return err == nil
+	})
+	return err
+}
+
+// Stream sends all items in order to the returned channel from a new
+// goroutine, and closes it at the end or once ctx is done, so that a
+// consumer stopping early should cancel ctx. The skiplist must not be
+// changed until the channel is closed.
+func (sl *SkipList) Stream(ctx context.Context) <-chan Item {
+	ch := make(chan Item)
+	go func() {
+		defer close(ch)
+		for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+			select {
+			case ch <- n.item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// MemEstimate returns a rough number of bytes taken by the skiplist, which
+// are the nodes with their levels, the head and the buffers. Neither the
+// items nor the allocator overhead are counted. O(N)
+func (sl *SkipList) MemEstimate() int {
+	nodeSize := int(unsafe.Sizeof(node{}))
+	levelSize := int(unsafe.Sizeof(nodeLevel{}))
+	size := int(unsafe.Sizeof(*sl))
+	size += cap(sl.buf)*int(unsafe.Sizeof((*node)(nil))) + cap(sl.ranks)*int(unsafe.Sizeof(0))
+	size += cap(sl.wranks) * int(unsafe.Sizeof(0.0))
+	size += cap(sl.tail)*int(unsafe.Sizeof((*node)(nil))) + cap(sl.tailRanks)*int(unsafe.Sizeof(0))
+	size += cap(sl.tailWeights) * int(unsafe.Sizeof(0.0))
+	for n := sl.head; n != nil; n = n.levels[0].forward {
+		size += nodeSize + cap(n.levels)*levelSize
+	}
+	return size
+}
+
+// ForEach calls f on each item in order until f returns false.
+func (sl *SkipList) ForEach(f func(item Item) bool) {
+	sl.ForEachFrom(nil, f)
+}
+
+// ForEachFrom calls f on each item >= start in order until f returns false,
+// if the start is nil, it starts on head.
+func (sl *SkipList) ForEachFrom(start Item, f func(item Item) bool) {
+	sl.RangeFunc(start, nil, f)
+}
+
+// CountFunc returns the number of items for which pred returns true. O(N)
+func (sl *SkipList) CountFunc(pred func(item Item) bool) int {
+	count := 0
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		if pred(n.item) {
+			count++
+		}
+	}
+	return count
+}
+
+// RangeFunc calls f on each item >= start and < stop in order until f
+// returns false. A nil start starts on head, a nil stop means no upper
+// bound.
+func (sl *SkipList) RangeFunc(start, stop Item, f func(item Item) bool) {
+	n := sl.head
+	if start != nil {
+		for i := sl.level - 1; i >= 0; i-- {
+			for n.levels[i].forward != nil && sl.less(n.levels[i].forward.item, start) {
+				n = n.levels[i].forward
+			}
+		}
+	}
+	for n = n.levels[0].forward; n != nil; n = n.levels[0].forward {
+		if stop != nil && !sl.less(n.item, stop) || !f(n.item) {
+			return
+		}
+	}
+}
+
+// WalkNodes calls f on each item in order with the level of its node, which
+// tells the exact layout, e.g. to rebuild it by WithLevelOf. O(N)
+func (sl *SkipList) WalkNodes(f func(item Item, level int)) {
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		f(n.item, len(n.levels))
+	}
+}
+
+// Validate checks the structure of the skiplist and returns an error on the
+// first violation found, debug purpose. O(N)
+func (sl *SkipList) Validate() error {
+	if sl.level < 0 || sl.level > sl.maxLevel || sl.level == 0 && sl.length > 0 {
+		return fmt.Errorf("skiplist: bad level %d", sl.level)
+	}
+	for i := sl.level; i < sl.maxLevel; i++ {
+		if sl.head.levels[i].forward != nil {
+			return fmt.Errorf("skiplist: head linked at level %d above level", i)
+		}
+	}
+	if !sl.keep && sl.length > 0 && sl.head.levels[sl.level-1].forward == nil {
+		return fmt.Errorf("skiplist: level %d is empty", sl.level-1)
+	}
+	// Level 0 defines the positions.
+	pos := make(map[*node]int, sl.length)
+	wpos := make(map[*node]float64, sl.length)
+	prev := sl.head
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		if _, ok := pos[n]; ok {
+			return fmt.Errorf("skiplist: level 0 has a cycle at %v", n.item)
+		}
+		pos[n] = len(pos) + 1
+		wpos[n] = wpos[prev] + weightOf(n.item)
+		if n.backward != prev {
+			return fmt.Errorf("skiplist: bad backward at %v", n.item)
+		}
+		if len(n.levels) > sl.level {
+			return fmt.Errorf("skiplist: node %v above level", n.item)
+		}
+		prev = n
+	}
+	if len(pos) != sl.length {
+		return fmt.Errorf("skiplist: length %d, but %d nodes", sl.length, len(pos))
+	}
+	var below map[*node]bool
+	for i := 0; i < sl.level; i++ {
+		seen := make(map[*node]bool)
+		prev := sl.head
+		for n := sl.head.levels[i].forward; n != nil; n = n.levels[i].forward {
+			p, ok := pos[n]
+			if !ok || p <= pos[prev] {
+				return fmt.Errorf("skiplist: node %v at level %d not at level 0", n.item, i)
+			}
+			if i > 0 && !below[n] {
+				return fmt.Errorf("skiplist: node %v at level %d not at level %d", n.item, i, i-1)
+			}
+			if len(n.levels) <= i {
+				return fmt.Errorf("skiplist: node %v linked above its level", n.item)
+			}
+			if prev != sl.head && sl.less(n.item, prev.item) {
+				return fmt.Errorf("skiplist: level %d not sorted at %v", i, n.item)
+			}
+			if prev.levels[i].span != p-pos[prev] {
+				return fmt.Errorf("skiplist: bad span before %v at level %d", n.item, i)
+			}
+			if w := wpos[n] - wpos[prev]; math.Abs(prev.levels[i].weight-w) > 1e-9*math.Max(1, math.Abs(w)) {
+				return fmt.Errorf("skiplist: bad weight before %v at level %d", n.item, i)
+			}
+			seen[n] = true
+			prev = n
+		}
+		if prev.levels[i].span != 0 || prev.levels[i].weight != 0 {
+			return fmt.Errorf("skiplist: bad span at the end of level %d", i)
+		}
+		below = seen
+	}
+	return nil
+}
+
+// String returns a one line summary of the skiplist, see Print for the
+// full structure.
+func (sl *SkipList) String() string {
+	return fmt.Sprintf("SkipList(len=%d, level=%d/%d)", sl.length, sl.level, sl.maxLevel)
+}
+
+// Print the skiplist, debug purpose.
+func (sl *SkipList) Print(w io.Writer) {
+	for i := 0; i < sl.level; i++ {
+		sl.printLevel(w, i, false)
+	}
+}
+
+// PrintVerbose prints the skiplist as Print does, with each forward link
+// annotated with its span, e.g. "head -(1)-> 1 -(3)-> 4 -> nil", debug
+// purpose.
+func (sl *SkipList) PrintVerbose(w io.Writer) {
+	for i := 0; i < sl.level; i++ {
+		sl.printLevel(w, i, true)
+	}
+}
+
+// PrintLevel prints a single level of the skiplist, debug purpose. It
+// fails if the level is not in [0, Level()).
+func (sl *SkipList) PrintLevel(w io.Writer, level int) error {
+	if level < 0 || level >= sl.level {
+		return fmt.Errorf("skiplist: bad level %d", level)
+	}
+	sl.printLevel(w, level, false)
+	return nil
+}
+
+// printLevel prints the level i, with the spans if verbose.
+func (sl *SkipList) printLevel(w io.Writer, i int, verbose bool) {
+	n := sl.head.levels[i].forward
+	fmt.Fprintf(w, "Level[%d]: ", i)
+	if verbose && n != nil {
+		fmt.Fprintf(w, "head -(%d)-> ", sl.head.levels[i].span)
+	}
+	for n != nil {
+		if verbose && n.levels[i].forward != nil {
+			fmt.Fprintf(w, "%v -(%d)-> ", n.item, n.levels[i].span)
+		} else {
 			fmt.Fprintf(w, "%v -> ", n.item)
-			n = n.forwards[i]
 		}
-		fmt.Fprintf(w, "nil\n")
+		n = n.levels[i].forward
 	}
+	fmt.Fprintf(w, "nil\n")
 }
@@ -0,0 +1,298 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// concurrentNode is an internel node in the ConcurrentSkipList.
+type concurrentNode struct {
+	item        Item
+	nexts       []unsafe.Pointer // *concurrentNode, accessed atomically.
+	mu          sync.Mutex
+	marked      int32 // 1 if being deleted.
+	fullyLinked int32 // 1 if linked at all its levels.
+}
+
+func newConcurrentNode(level int, item Item) *concurrentNode {
+	return &concurrentNode{
+		item:  item,
+		nexts: make([]unsafe.Pointer, level, level),
+	}
+}
+
+func (n *concurrentNode) next(i int) *concurrentNode {
+	return (*concurrentNode)(atomic.LoadPointer(&n.nexts[i]))
+}
+
+func (n *concurrentNode) setNext(i int, x *concurrentNode) {
+	atomic.StorePointer(&n.nexts[i], unsafe.Pointer(x))
+}
+
+func (n *concurrentNode) isMarked() bool {
+	return atomic.LoadInt32(&n.marked) == 1
+}
+
+func (n *concurrentNode) isFullyLinked() bool {
+	return atomic.LoadInt32(&n.fullyLinked) == 1
+}
+
+// ConcurrentSkipList is a skiplist safe for concurrent use, with a lock on
+// each node instead of a global one, so that goroutines working on disjoint
+// regions don't block each other. It's the lazy skiplist by Herlihy et al:
+// searches take no locks, Put and Delete lock the predecessors of the node
+// at each level, validate and then link or unlink it.
+//
+// Unlike SkipList, it's a set: equal items are not stored twice.
+type ConcurrentSkipList struct {
+	length   int64 // Accessed atomically.
+	maxLevel int
+	factorP  float64
+	head     *concurrentNode // Before any item.
+	tail     *concurrentNode // After any item.
+	randMu   sync.Mutex
+	rand     *rand.Rand
+}
+
+// NewConcurrent creates a new ConcurrentSkipList.
+func NewConcurrent(maxLevel int) *ConcurrentSkipList {
+	if maxLevel < 2 {
+		panic("skiplist: bad maxLevel")
+	}
+	sl := &ConcurrentSkipList{
+		maxLevel: maxLevel,
+		factorP:  FactorP,
+		head:     newConcurrentNode(maxLevel, nil),
+		tail:     newConcurrentNode(maxLevel, nil),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for i := 0; i < maxLevel; i++ {
+		sl.head.setNext(i, sl.tail)
+	}
+	sl.head.fullyLinked, sl.tail.fullyLinked = 1, 1
+	return sl
+}
+
+// Len returns skiplist length.
+func (sl *ConcurrentSkipList) Len() int { return int(atomic.LoadInt64(&sl.length)) }
+
+// MaxLevel returns skiplist maxLevel.
+func (sl *ConcurrentSkipList) MaxLevel() int { return sl.maxLevel }
+
+// randLevel returns a level between 1 and maxLevel.
+func (sl *ConcurrentSkipList) randLevel() int {
+	sl.randMu.Lock()
+	defer sl.randMu.Unlock()
+	level := 1
+	for level < sl.maxLevel && sl.rand.Intn(0x10000) < int(sl.factorP*float64(0xffff)) {
+		level++
+	}
+	return level
+}
+
+// before tests whether node n is before the item.
+func (sl *ConcurrentSkipList) before(n *concurrentNode, item Item) bool {
+	switch n {
+	case sl.head:
+		return true
+	case sl.tail:
+		return false
+	}
+	return n.item.Less(item)
+}
+
+// find finds the rightmost node before the item and the one after it at
+// each level into preds and succs, and returns the highest level the item
+// is found at, -1 on not found.
+func (sl *ConcurrentSkipList) find(item Item, preds, succs []*concurrentNode) int {
+	found := -1
+	pred := sl.head
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		curr := pred.next(i)
+		for sl.before(curr, item) {
+			pred, curr = curr, curr.next(i)
+		}
+		if found == -1 && curr != sl.tail && !item.Less(curr.item) {
+			found = i
+		}
+		preds[i], succs[i] = pred, curr
+	}
+	return found
+}
+
+// lockPreds locks the distinct preds from level 0 up to top while valid
+// tells they're still good, and returns the highest level locked and
+// whether all are valid.
+func lockPreds(preds []*concurrentNode, top int, valid func(i int) bool) (int, bool) {
+	highest := -1
+	var prev *concurrentNode
+	for i := 0; i < top; i++ {
+		if preds[i] != prev {
+			preds[i].mu.Lock()
+			highest, prev = i, preds[i]
+		}
+		if !valid(i) {
+			return highest, false
+		}
+	}
+	return highest, true
+}
+
+// unlockPreds unlocks the preds locked by lockPreds.
+func unlockPreds(preds []*concurrentNode, highest int) {
+	var prev *concurrentNode
+	for i := 0; i <= highest; i++ {
+		if preds[i] != prev {
+			preds[i].mu.Unlock()
+			prev = preds[i]
+		}
+	}
+}
+
+// Put adds an item to the skiplist, and returns false if an equal item
+// exists already. O(logN)
+func (sl *ConcurrentSkipList) Put(item Item) bool {
+	level := sl.randLevel()
+	preds := make([]*concurrentNode, sl.maxLevel)
+	succs := make([]*concurrentNode, sl.maxLevel)
+	for {
+		if found := sl.find(item, preds, succs); found != -1 {
+			n := succs[found]
+			if !n.isMarked() {
+				for !n.isFullyLinked() { // Wait for the other Put.
+					runtime.Gosched()
+				}
+				return false
+			}
+			continue // Wait for the other Delete.
+		}
+		highest, valid := lockPreds(preds, level, func(i int) bool {
+			return !preds[i].isMarked() && !succs[i].isMarked() && preds[i].next(i) == succs[i]
+		})
+		if !valid {
+			unlockPreds(preds, highest)
+			continue
+		}
+		n := newConcurrentNode(level, item)
+		for i := 0; i < level; i++ {
+			n.nexts[i] = unsafe.Pointer(succs[i])
+		}
+		for i := 0; i < level; i++ {
+			preds[i].setNext(i, n)
+		}
+		atomic.StoreInt32(&n.fullyLinked, 1)
+		unlockPreds(preds, highest)
+		atomic.AddInt64(&sl.length, 1)
+		return true
+	}
+}
+
+// Get an item from the skiplist, nil on not found. Takes no lock. O(logN)
+func (sl *ConcurrentSkipList) Get(item Item) Item {
+	pred := sl.head
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		curr := pred.next(i)
+		for sl.before(curr, item) {
+			pred, curr = curr, curr.next(i)
+		}
+		if curr != sl.tail && !item.Less(curr.item) {
+			if curr.isFullyLinked() && !curr.isMarked() {
+				return curr.item
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Has tests whether skiplist contains an item. O(logN)
+func (sl *ConcurrentSkipList) Has(item Item) bool { return sl.Get(item) != nil }
+
+// Delete an item from skiplist and return it, nil on not found. O(logN)
+func (sl *ConcurrentSkipList) Delete(item Item) Item {
+	preds := make([]*concurrentNode, sl.maxLevel)
+	succs := make([]*concurrentNode, sl.maxLevel)
+	var victim *concurrentNode
+	for {
+		found := sl.find(item, preds, succs)
+		if victim == nil {
+			if found == -1 {
+				return nil
+			}
+			victim = succs[found]
+			if !victim.isFullyLinked() || len(victim.nexts)-1 != found || victim.isMarked() {
+				return nil
+			}
+			victim.mu.Lock()
+			if victim.isMarked() {
+				victim.mu.Unlock()
+				return nil
+			}
+			atomic.StoreInt32(&victim.marked, 1)
+		}
+		level := len(victim.nexts)
+		highest, valid := lockPreds(preds, level, func(i int) bool {
+			return !preds[i].isMarked() && preds[i].next(i) == victim
+		})
+		if !valid {
+			unlockPreds(preds, highest)
+			continue
+		}
+		for i := level - 1; i >= 0; i-- {
+			preds[i].setNext(i, victim.next(i))
+		}
+		victim.mu.Unlock()
+		unlockPreds(preds, highest)
+		atomic.AddInt64(&sl.length, -1)
+		return victim.item
+	}
+}
+
+// ForEach calls f on each item in order until f returns false. Items put or
+// deleted during the traversal may or may not be seen.
+func (sl *ConcurrentSkipList) ForEach(f func(item Item) bool) {
+	for n := sl.head.next(0); n != sl.tail; n = n.next(0) {
+		if n.isFullyLinked() && !n.isMarked() && !f(n.item) {
+			return
+		}
+	}
+}
+
+// Validate checks the structure of the skiplist and returns an error on the
+// first violation found, it must be called with no Put or Delete going on.
+// O(N)
+func (sl *ConcurrentSkipList) Validate() error {
+	seen := make(map[*concurrentNode]bool)
+	for i := 0; i < sl.maxLevel; i++ {
+		below := seen
+		seen = make(map[*concurrentNode]bool)
+		prev := sl.head
+		for n := sl.head.next(i); n != sl.tail; n = n.next(i) {
+			if n == nil {
+				return fmt.Errorf("skiplist: level %d not ended by tail", i)
+			}
+			if n.isMarked() || !n.isFullyLinked() {
+				return fmt.Errorf("skiplist: node %v half linked", n.item)
+			}
+			if prev != sl.head && !prev.item.Less(n.item) {
+				return fmt.Errorf("skiplist: level %d not sorted at %v", i, n.item)
+			}
+			if i > 0 && !below[n] {
+				return fmt.Errorf("skiplist: node %v at level %d not at level %d", n.item, i, i-1)
+			}
+			seen[n] = true
+			prev = n
+		}
+		if i == 0 && len(seen) != sl.Len() {
+			return fmt.Errorf("skiplist: length %d, but %d nodes", sl.Len(), len(seen))
+		}
+	}
+	return nil
+}
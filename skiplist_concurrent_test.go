@@ -0,0 +1,78 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSkipList(t *testing.T) {
+	sl := NewConcurrent(16)
+	Must(t, sl.Get(Int(1)) == nil)
+	Must(t, sl.Delete(Int(1)) == nil)
+	Must(t, sl.Put(Int(1)))
+	Must(t, !sl.Put(Int(1)))
+	Must(t, sl.Delete(Int(1)) == Int(1))
+	Must(t, sl.Len() == 0)
+
+	var wg sync.WaitGroup
+	n, workers := 2048, 16
+	// Disjoint ranges.
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w * n; i < (w+1)*n; i++ {
+				Must(t, sl.Put(Int(i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	Must(t, sl.Len() == n*workers)
+	Must(t, sl.Validate() == nil)
+	// Interleaved ranges, with the odd ones deleted.
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n*workers; i += workers {
+				Must(t, sl.Has(Int(i)))
+				if i%2 == 1 {
+					Must(t, sl.Delete(Int(i)) == Int(i))
+				}
+				sl.Put(Int(i + n*workers))
+			}
+		}(w)
+	}
+	wg.Wait()
+	Must(t, sl.Len() == n*workers*3/2)
+	Must(t, sl.Validate() == nil)
+	i := 0
+	sl.ForEach(func(item Item) bool {
+		Must(t, item == Int(i))
+		if i < n*workers {
+			i += 2
+		} else {
+			i++
+		}
+		return true
+	})
+	Must(t, i == n*workers*2)
+	// Racing on the same items.
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 256; i++ {
+				sl.Delete(Int(i))
+				sl.Put(Int(i))
+			}
+		}()
+	}
+	wg.Wait()
+	Must(t, sl.Validate() == nil)
+	for i := 0; i < 256; i++ {
+		Must(t, sl.Has(Int(i)))
+	}
+}
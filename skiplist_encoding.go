@@ -0,0 +1,76 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// binarySkipList is the gob form of a SkipList.
+type binarySkipList struct {
+	MaxLevel int
+	Items    []Item
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, it encodes maxLevel
+// and the items in order with gob. The concrete types of the items must be
+// registered by gob.Register.
+func (sl *SkipList) MarshalBinary() ([]byte, error) {
+	b := binarySkipList{
+		MaxLevel: sl.maxLevel,
+		Items:    make([]Item, 0, sl.length),
+	}
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		b.Items = append(b.Items, n.item)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(b); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it replaces the
+// skiplist with the one encoded by MarshalBinary. The options, comparator
+// and factorP of the skiplist are kept, and its secondary indexes are
+// rebuilt with the new items. The levels are randomized again.
+func (sl *SkipList) UnmarshalBinary(data []byte) error {
+	var b binarySkipList
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&b); err != nil {
+		return err
+	}
+	if b.MaxLevel < 2 {
+		return errors.New("skiplist: bad maxLevel")
+	}
+	var c *SkipList
+	if sl.factorP == 0 { // Zero SkipList
+		c = NewWithOptions(b.MaxLevel, FactorP, time.Now().UnixNano())
+	} else {
+		c = sl.newLike(b.MaxLevel)
+		c.rand = sl.rand
+	}
+	for _, idx := range sl.indexes {
+		idx.Clear()
+	}
+	c.indexes = sl.indexes
+	for _, item := range b.Items {
+		c.Put(item)
+	}
+	*sl = *c
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, it encodes the items in order as a
+// JSON array, each item by encoding/json. There's no UnmarshalJSON as the
+// concrete types of the items are unknown.
+func (sl *SkipList) MarshalJSON() ([]byte, error) {
+	items := make([]Item, 0, sl.length)
+	for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		items = append(items, n.item)
+	}
+	return json.Marshal(items)
+}
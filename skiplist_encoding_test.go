@@ -0,0 +1,78 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func init() {
+	gob.Register(Int(0))
+}
+
+func TestMarshalBinary(t *testing.T) {
+	sl := New(12)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(Int(rand.Intn(n)))
+	}
+	data, err := sl.MarshalBinary()
+	Must(t, err == nil)
+	var c SkipList
+	Must(t, c.UnmarshalBinary(data) == nil)
+	Must(t, c.Len() == sl.Len())
+	Must(t, c.MaxLevel() == sl.MaxLevel())
+	a, b := sl.NewIterator(nil), c.NewIterator(nil)
+	for a.Next() {
+		Must(t, b.Next())
+		Must(t, a.Item() == b.Item())
+	}
+	Must(t, !b.Next())
+	// Empty
+	data, err = New(4).MarshalBinary()
+	Must(t, err == nil)
+	Must(t, c.UnmarshalBinary(data) == nil)
+	Must(t, c.Len() == 0 && c.MaxLevel() == 4)
+	// Bad data
+	Must(t, c.UnmarshalBinary([]byte("bad")) != nil)
+}
+
+func TestUnmarshalBinaryKeepsOptions(t *testing.T) {
+	src := New(8)
+	for _, i := range []int{3, 1, 2} {
+		src.Put(Int(i))
+	}
+	data, err := src.MarshalBinary()
+	Must(t, err == nil)
+	sl := New(8, WithUpdateOnEqual())
+	sl.Put(Int(7))
+	sl.Put(Int(8))
+	idx := sl.AddSecondaryIndex(func(a, b Item) bool { return b.Less(a) })
+	Must(t, sl.UnmarshalBinary(data) == nil)
+	Must(t, sl.Len() == 3 && idx.Len() == 3)
+	Must(t, idx.First() == Int(3) && idx.Last() == Int(1))
+	// Still synced and upserting.
+	sl.Put(Int(2))
+	sl.Put(Int(4))
+	Must(t, sl.Len() == 4 && idx.Len() == 4 && idx.First() == Int(4))
+	Must(t, sl.Validate() == nil && idx.Validate() == nil)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	sl := New(8)
+	data, err := json.Marshal(sl)
+	Must(t, err == nil && string(data) == "[]")
+	for _, i := range []int{3, 1, 2, -5, 2} {
+		sl.Put(Int(i))
+	}
+	data, err = json.Marshal(sl)
+	Must(t, err == nil && string(data) == "[-5,1,2,2,3]")
+	sl = New(8)
+	sl.Put(String("b"))
+	sl.Put(String("a"))
+	data, err = json.Marshal(sl)
+	Must(t, err == nil && string(data) == `["a","b"]`)
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import "fmt"
+
+// tomb holds an item in a LazySkipList, dead once deleted. The item is kept
+// to order the tombstone until it's swept.
+type tomb struct {
+	item Item
+	dead bool
+}
+
+// Less returns true if the held item is less than the other's.
+func (t *tomb) Less(than Item) bool {
+	return t.item.Less(than.(*tomb).item)
+}
+
+// LazySkipList is a SkipList deleting lazily: Delete marks the item's node
+// as a tombstone, which is left linked and skipped by the reads, and a Put
+// of an equal item revives the tombstone in place, without relinking. The
+// tombstones are swept by Compact, or once there're too many of them.
+type LazySkipList struct {
+	sl      *SkipList
+	dead    int     // Number of tombstones.
+	sweepAt float64 // Ratio of tombstones to sweep at, 0 for never.
+	probe   tomb    // Reused to look up items.
+}
+
+// LazyIterator is LazySkipList iterator, skipping the tombstones.
+type LazyIterator struct {
+	iter *Iterator
+}
+
+// NewLazy creates a new LazySkipList, which sweeps the tombstones once they
+// are more than sweepAt of all the nodes, e.g. 0.5, or only on Compact if
+// sweepAt is 0. It panics if sweepAt is not in [0, 1).
+func NewLazy(maxLevel int, sweepAt float64) *LazySkipList {
+	if !(sweepAt >= 0 && sweepAt < 1) {
+		panic(fmt.Sprintf("skiplist: bad sweepAt %v", sweepAt))
+	}
+	return &LazySkipList{sl: New(maxLevel), sweepAt: sweepAt}
+}
+
+// Len returns the number of live items, the tombstones not counted.
+func (l *LazySkipList) Len() int { return l.sl.length - l.dead }
+
+// Tombstones returns the number of deleted items not swept yet.
+func (l *LazySkipList) Tombstones() int { return l.dead }
+
+// find returns the node of the first item equal to the given item, or the
+// node after, nil if there's none. The nodes before are left in l.sl.buf.
+func (l *LazySkipList) find(item Item) *node {
+	l.probe.item = item
+	n := l.sl.search(&l.probe, false).levels[0].forward
+	l.probe.item = nil
+	return n
+}
+
+// findLive returns the node of the first live item equal to the given item,
+// nil on not found.
+func (l *LazySkipList) findLive(item Item) *node {
+	for n := l.find(item); n != nil; n = n.levels[0].forward {
+		t := n.item.(*tomb)
+		if !equal(t.item, item) {
+			return nil
+		}
+		if !t.dead {
+			return n
+		}
+	}
+	return nil
+}
+
+// Put adds an item to the skiplist, in front of the items equal to it,
+// reviving the first of them instead if it's a tombstone. O(logN)
+func (l *LazySkipList) Put(item Item) {
+	n := l.find(item)
+	if n != nil {
+		if t := n.item.(*tomb); t.dead && equal(t.item, item) {
+			t.item, t.dead = item, false
+			l.dead--
+			return
+		}
+	}
+	l.sl.insertNode(&tomb{item: item})
+}
+
+// Get the first live item equal to the given item, nil on not found.
+// O(logN), plus the tombstones passed over.
+func (l *LazySkipList) Get(item Item) Item {
+	if n := l.findLive(item); n != nil {
+		return n.item.(*tomb).item
+	}
+	return nil
+}
+
+// Has tests whether skiplist contains a live item equal to the given item.
+// O(logN), plus the tombstones passed over.
+func (l *LazySkipList) Has(item Item) bool { return l.findLive(item) != nil }
+
+// Delete the first live item equal to the given item and return it, nil on
+// not found. Its node is left as a tombstone, unless it's the tombstone
+// that makes a sweep due. O(logN), plus the tombstones passed over.
+func (l *LazySkipList) Delete(item Item) Item {
+	n := l.findLive(item)
+	if n == nil {
+		return nil
+	}
+	t := n.item.(*tomb)
+	t.dead = true
+	l.dead++
+	if l.sweepAt > 0 && float64(l.dead) > l.sweepAt*float64(l.sl.length) {
+		l.Compact()
+	}
+	return t.item
+}
+
+// First returns the first live item, nil on not found. O(1), plus the
+// tombstones passed over.
+func (l *LazySkipList) First() Item {
+	for n := l.sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+		if t := n.item.(*tomb); !t.dead {
+			return t.item
+		}
+	}
+	return nil
+}
+
+// Compact sweeps the tombstones, which unlinks their nodes in one pass.
+// O(N)
+func (l *LazySkipList) Compact() {
+	l.sl.RetainFunc(func(item Item) bool { return !item.(*tomb).dead })
+	l.dead = 0
+}
+
+// Clear the skiplist, the tombstones too.
+func (l *LazySkipList) Clear() {
+	l.sl.Clear()
+	l.dead = 0
+}
+
+// Range calls f on each live item in order until f returns false.
+func (l *LazySkipList) Range(f func(item Item) bool) {
+	iter := l.NewIterator(nil)
+	for iter.Next() {
+		if !f(iter.Item()) {
+			return
+		}
+	}
+}
+
+// NewIterator returns a new iterator on the live items >= start, if the
+// start is nil, iterator starts on head. A sweep invalidates it.
+func (l *LazySkipList) NewIterator(start Item) *LazyIterator {
+	iter := &LazyIterator{iter: l.sl.NewIterator(nil)}
+	if start != nil {
+		l.probe.item = start
+		iter.iter.Seek(&l.probe)
+		l.probe.item = nil
+	}
+	return iter
+}
+
+// Next seeks iterator next live item, returns false on end.
+func (iter *LazyIterator) Next() bool {
+	for iter.iter.Next() {
+		if !iter.iter.Item().(*tomb).dead {
+			return true
+		}
+	}
+	return false
+}
+
+// Item returns current item on the iterator.
+func (iter *LazyIterator) Item() Item {
+	return iter.iter.Item().(*tomb).item
+}
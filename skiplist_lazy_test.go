@@ -0,0 +1,96 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestLazySkipList(t *testing.T) {
+	l := NewLazy(16, 0)
+	Must(t, l.First() == nil && l.Len() == 0)
+	n := 1000
+	for i := 0; i < n; i++ {
+		l.Put(Int(i))
+	}
+	for i := 0; i < n; i += 2 {
+		Must(t, l.Delete(Int(i)) == Int(i))
+	}
+	Must(t, l.Delete(Int(0)) == nil)
+	// Invisible to the queries, still linked.
+	Must(t, l.Len() == n/2 && l.Tombstones() == n/2)
+	Must(t, l.sl.Len() == n)
+	Must(t, l.First() == Int(1))
+	for i := 0; i < n; i++ {
+		Must(t, l.Has(Int(i)) == (i%2 == 1))
+		Must(t, (l.Get(Int(i)) == nil) == (i%2 == 0))
+	}
+	i := 1
+	l.Range(func(item Item) bool {
+		Must(t, item == Int(i))
+		i += 2
+		return true
+	})
+	Must(t, i == n+1)
+	iter := l.NewIterator(Int(10))
+	Must(t, iter.Next() && iter.Item() == Int(11))
+	// Revived in place.
+	l.Put(Int(10))
+	Must(t, l.Has(Int(10)) && l.Len() == n/2+1 && l.sl.Len() == n)
+	// Swept by Compact.
+	l.Compact()
+	Must(t, l.Len() == n/2+1 && l.Tombstones() == 0 && l.sl.Len() == n/2+1)
+	Must(t, l.sl.Validate() == nil)
+	Must(t, l.Has(Int(10)) && !l.Has(Int(12)))
+	l.Clear()
+	Must(t, l.Len() == 0 && l.Tombstones() == 0)
+}
+
+func TestLazySkipListDuplicates(t *testing.T) {
+	l := NewLazy(8, 0)
+	l.Put(scoreItem{1, "a"})
+	l.Put(scoreItem{1, "b"})
+	Must(t, l.Delete(scoreItem{score: 1}).(scoreItem).value == "b")
+	// Skips the tombstone in front.
+	Must(t, l.Get(scoreItem{score: 1}).(scoreItem).value == "a")
+	Must(t, l.Delete(scoreItem{score: 1}).(scoreItem).value == "a")
+	Must(t, !l.Has(scoreItem{score: 1}) && l.Len() == 0)
+	l.Put(scoreItem{1, "c"})
+	Must(t, l.Get(scoreItem{score: 1}).(scoreItem).value == "c")
+	Must(t, l.Len() == 1 && l.Tombstones() == 1)
+}
+
+func TestLazySkipListSweepAt(t *testing.T) {
+	l := NewLazy(16, 0.25)
+	n := 1000
+	for _, i := range rand.Perm(n) {
+		l.Put(Int(i))
+	}
+	for i := 0; i < n; i++ {
+		l.Delete(Int(i))
+		Must(t, l.Len() == n-i-1)
+		Must(t, float64(l.Tombstones()) <= 0.25*float64(l.sl.Len()))
+	}
+	Must(t, l.sl.Validate() == nil)
+	for _, sweepAt := range []float64{-1, 1} {
+		func() {
+			defer func() { Must(t, recover() != nil) }()
+			NewLazy(16, sweepAt)
+		}()
+	}
+}
+
+// Compare with BenchmarkChurn.
+func BenchmarkLazyChurn(b *testing.B) {
+	l := NewLazy(50, 0.5)
+	for i := 0; i < 1024; i++ {
+		l.Put(Int(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Delete(Int(i % 1024))
+		l.Put(Int(i % 1024))
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+// Map is an ordered map on a SkipList, keys are Items and values can be
+// anything.
+type Map struct {
+	sl *SkipList
+}
+
+// MapIterator is Map iterator.
+type MapIterator struct {
+	iter *Iterator
+}
+
+// keyValue is the item of a Map, ordered by the key.
+type keyValue struct {
+	key Item
+	val interface{}
+}
+
+// Less returns true if the key is less than the other's.
+func (kv keyValue) Less(than Item) bool {
+	return kv.key.Less(than.(keyValue).key)
+}
+
+// NewMap creates a new Map.
+func NewMap(maxLevel int) *Map {
+	return &Map{sl: New(maxLevel, WithUpdateOnEqual())}
+}
+
+// Len returns the number of keys.
+func (m *Map) Len() int { return m.sl.Len() }
+
+// Set sets the value for a key, the old value is overwritten. O(logN)
+func (m *Map) Set(key Item, val interface{}) {
+	m.sl.Put(keyValue{key, val})
+}
+
+// Get returns the value for a key, ok is false on not found. O(logN)
+func (m *Map) Get(key Item) (val interface{}, ok bool) {
+	if item := m.sl.Get(keyValue{key: key}); item != nil {
+		return item.(keyValue).val, true
+	}
+	return nil, false
+}
+
+// Has tests whether the map contains a key. O(logN)
+func (m *Map) Has(key Item) bool { return m.sl.Has(keyValue{key: key}) }
+
+// Delete a key from the map, returns false on not found. O(logN)
+func (m *Map) Delete(key Item) bool {
+	return m.sl.Delete(keyValue{key: key}) != nil
+}
+
+// NewIterator returns a new iterator on this map with a key start, if the
+// start is nil, iterator starts on head. Filter keys >= start.
+func (m *Map) NewIterator(start Item) *MapIterator {
+	if start == nil {
+		return &MapIterator{m.sl.NewIterator(nil)}
+	}
+	return &MapIterator{m.sl.NewIterator(keyValue{key: start})}
+}
+
+// Next seeks iterator next, returns false on end. O(1)
+func (iter *MapIterator) Next() bool { return iter.iter.Next() }
+
+// Key returns current key on the iterator.
+func (iter *MapIterator) Key() Item { return iter.iter.Item().(keyValue).key }
+
+// Value returns current value on the iterator.
+func (iter *MapIterator) Value() interface{} { return iter.iter.Item().(keyValue).val }
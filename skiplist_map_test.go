@@ -0,0 +1,43 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	m := NewMap(8)
+	_, ok := m.Get(Int(1))
+	Must(t, !ok)
+	n := 100
+	for _, i := range rand.Perm(n) {
+		m.Set(Int(i), i*10)
+	}
+	Must(t, m.Len() == n)
+	// Overwrite
+	m.Set(Int(5), "five")
+	Must(t, m.Len() == n)
+	val, ok := m.Get(Int(5))
+	Must(t, ok && val == "five")
+	val, ok = m.Get(Int(6))
+	Must(t, ok && val == 60)
+	Must(t, m.Has(Int(7)))
+	Must(t, m.Delete(Int(7)))
+	Must(t, !m.Delete(Int(7)))
+	Must(t, !m.Has(Int(7)) && m.Len() == n-1)
+	// Ordered pairs
+	iter := m.NewIterator(Int(90))
+	for i := 90; i < n; i++ {
+		Must(t, iter.Next())
+		Must(t, iter.Key() == Int(i) && iter.Value() == i*10)
+	}
+	Must(t, !iter.Next())
+	iter = m.NewIterator(nil)
+	Must(t, iter.Next() && iter.Key() == Int(0))
+	// Nil values are fine.
+	m.Set(Int(-1), nil)
+	val, ok = m.Get(Int(-1))
+	Must(t, ok && val == nil)
+}
@@ -0,0 +1,194 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+// The go.mod says go 1.15. Since Go 1.21 the constraint below lifts the
+// language version of this file for type parameters, Go 1.18 to 1.20 would
+// keep go1.15 and fail.
+
+//go:build go1.21
+// +build go1.21
+
+package skiplist
+
+import (
+	"math/rand"
+	"time"
+)
+
+// orderedNode is an internel node in the Ordered skiplist.
+type orderedNode[T any] struct {
+	item     T
+	forwards []*orderedNode[T]
+}
+
+// Ordered is a skiplist of items of type T, ordered by a less function
+// given on construction. It saves the boxing of items into the Item
+// interface and the dynamic dispatch of Less.
+type Ordered[T any] struct {
+	length   int
+	level    int
+	maxLevel int
+	less     func(a, b T) bool
+	head     *orderedNode[T]
+	rand     *rand.Rand
+	buf      []*orderedNode[T]
+}
+
+// OrderedIterator is Ordered skiplist iterator.
+type OrderedIterator[T any] struct {
+	n *orderedNode[T]
+}
+
+func newOrderedNode[T any](level int, item T) *orderedNode[T] {
+	return &orderedNode[T]{
+		item:     item,
+		forwards: make([]*orderedNode[T], level, level),
+	}
+}
+
+// NewOrdered creates a new Ordered skiplist, less must be a strict less,
+// we treat !less(a, b) && !less(b, a) to mean a == b.
+func NewOrdered[T any](maxLevel int, less func(a, b T) bool) *Ordered[T] {
+	if maxLevel < 2 {
+		panic("skiplist: bad maxLevel")
+	}
+	var zero T
+	return &Ordered[T]{
+		maxLevel: maxLevel,
+		less:     less,
+		head:     newOrderedNode(maxLevel, zero),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		buf:      make([]*orderedNode[T], maxLevel, maxLevel),
+	}
+}
+
+// Len returns skiplist length.
+func (sl *Ordered[T]) Len() int { return sl.length }
+
+// Level returns skiplist level.
+func (sl *Ordered[T]) Level() int { return sl.level }
+
+// MaxLevel returns skiplist maxLevel.
+func (sl *Ordered[T]) MaxLevel() int { return sl.maxLevel }
+
+// randLevel returns a level between 1 and maxLevel.
+func (sl *Ordered[T]) randLevel() int {
+	level := 1
+	for sl.rand.Int()&0xffff < int(FactorP*float64(0xffff)) {
+		level++
+	}
+	if level < sl.maxLevel {
+		return level
+	}
+	return sl.maxLevel
+}
+
+// equal tests whether a equals b.
+func (sl *Ordered[T]) equal(a, b T) bool {
+	return !sl.less(a, b) && !sl.less(b, a)
+}
+
+// search finds the rightmost node before the item at each level into
+// sl.buf, and returns the one at level 0.
+func (sl *Ordered[T]) search(item T) *orderedNode[T] {
+	n := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.forwards[i] != nil && sl.less(n.forwards[i].item, item) {
+			n = n.forwards[i]
+		}
+		sl.buf[i] = n
+	}
+	return n
+}
+
+// Put adds an item to the skiplist, in front of the items equal to it.
+// O(logN)
+func (sl *Ordered[T]) Put(item T) {
+	update := sl.buf
+	sl.search(item)
+	level := sl.randLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			update[i] = sl.head
+		}
+		sl.level = level
+	}
+	n := newOrderedNode(level, item)
+	for i := 0; i < level; i++ {
+		n.forwards[i] = update[i].forwards[i]
+		update[i].forwards[i] = n
+	}
+	sl.length++
+}
+
+// Get an item from the skiplist, ok is false on not found. O(logN)
+func (sl *Ordered[T]) Get(item T) (found T, ok bool) {
+	n := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.forwards[i] != nil && sl.less(n.forwards[i].item, item) {
+			n = n.forwards[i]
+		}
+	}
+	if n = n.forwards[0]; n != nil && sl.equal(n.item, item) {
+		return n.item, true
+	}
+	return found, false
+}
+
+// Has tests whether skiplist contains an item. O(logN)
+func (sl *Ordered[T]) Has(item T) bool {
+	_, ok := sl.Get(item)
+	return ok
+}
+
+// Delete an item from skiplist and return it, ok is false on not found.
+// O(logN)
+func (sl *Ordered[T]) Delete(item T) (deleted T, ok bool) {
+	update := sl.buf
+	n := sl.search(item).forwards[0]
+	if n == nil || !sl.equal(n.item, item) {
+		return deleted, false
+	}
+	for i := 0; i < sl.level; i++ {
+		if update[i].forwards[i] == n {
+			update[i].forwards[i] = n.forwards[i]
+		}
+	}
+	for sl.level > 1 && sl.head.forwards[sl.level-1] == nil {
+		sl.level--
+	}
+	sl.length--
+	return n.item, true
+}
+
+// First returns the first item, ok is false on empty. O(1)
+func (sl *Ordered[T]) First() (first T, ok bool) {
+	if sl.length == 0 {
+		return first, false
+	}
+	return sl.head.forwards[0].item, true
+}
+
+// NewIterator returns a new iterator on this skiplist starting on head.
+func (sl *Ordered[T]) NewIterator() *OrderedIterator[T] {
+	return &OrderedIterator[T]{n: sl.head}
+}
+
+// NewIteratorFrom returns a new iterator on this skiplist, filter items
+// >= start.
+func (sl *Ordered[T]) NewIteratorFrom(start T) *OrderedIterator[T] {
+	return &OrderedIterator[T]{n: sl.search(start)}
+}
+
+// Next seeks iterator next, returns false on end.
+func (iter *OrderedIterator[T]) Next() bool {
+	if iter.n == nil {
+		return false
+	}
+	iter.n = iter.n.forwards[0]
+	return iter.n != nil
+}
+
+// Item returns current item on the iterator.
+func (iter *OrderedIterator[T]) Item() T {
+	return iter.n.item
+}
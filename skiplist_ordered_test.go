@@ -0,0 +1,70 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+// The go.mod says go 1.15. Since Go 1.21 the constraint below lifts the
+// language version of this file for type parameters, Go 1.18 to 1.20 would
+// keep go1.15 and fail.
+
+//go:build go1.21
+// +build go1.21
+
+package skiplist
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func intLess(a, b int) bool { return a < b }
+
+func TestOrdered(t *testing.T) {
+	sl := NewOrdered(16, intLess)
+	_, ok := sl.First()
+	Must(t, !ok)
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(i)
+	}
+	Must(t, sl.Len() == n)
+	first, ok := sl.First()
+	Must(t, ok && first == 0)
+	for i := 0; i < n; i++ {
+		item, ok := sl.Get(i)
+		Must(t, ok && item == i)
+	}
+	Must(t, !sl.Has(n))
+	for i := 0; i < n; i += 2 {
+		item, ok := sl.Delete(i)
+		Must(t, ok && item == i)
+	}
+	_, ok = sl.Delete(0)
+	Must(t, !ok)
+	Must(t, sl.Len() == n/2)
+	iter := sl.NewIterator()
+	i := 1
+	for iter.Next() {
+		Must(t, iter.Item() == i)
+		i += 2
+	}
+	Must(t, i == n+1)
+	iter = sl.NewIteratorFrom(n / 2)
+	Must(t, iter.Next())
+	Must(t, iter.Item() == n/2+1)
+}
+
+func BenchmarkOrderedPut(b *testing.B) {
+	sl := NewOrdered(50, intLess)
+	for i := 0; i < b.N; i++ {
+		sl.Put(i)
+	}
+}
+
+func BenchmarkOrderedGet(b *testing.B) {
+	sl := NewOrdered(50, intLess)
+	for i := 0; i < b.N; i++ {
+		sl.Put(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sl.Get(i)
+	}
+}
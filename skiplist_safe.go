@@ -0,0 +1,87 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import "sync"
+
+// SafeSkipList is a SkipList guarded by a sync.RWMutex, safe for concurrent
+// use by multiple goroutines. The plain SkipList remains unsynchronized for
+// callers who want their own locking.
+type SafeSkipList struct {
+	mu sync.RWMutex
+	sl *SkipList
+}
+
+// NewSafe creates a new SafeSkipList.
+func NewSafe(maxLevel int) *SafeSkipList {
+	return &SafeSkipList{sl: New(maxLevel)}
+}
+
+// Put adds an item to the skiplist. O(logN)
+func (s *SafeSkipList) Put(item Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sl.Put(item)
+}
+
+// Delete an item from skiplist and return it, nil on not found. O(logN)
+func (s *SafeSkipList) Delete(item Item) Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sl.Delete(item)
+}
+
+// Clear the skiplist.
+func (s *SafeSkipList) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sl.Clear()
+}
+
+// Get an item from the skiplist, nil on not found. O(logN)
+func (s *SafeSkipList) Get(item Item) Item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sl.Get(item)
+}
+
+// Has tests whether skiplist contains an item. O(logN)
+func (s *SafeSkipList) Has(item Item) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sl.Has(item)
+}
+
+// First returns the first item, nil on not found. O(1)
+func (s *SafeSkipList) First() Item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sl.First()
+}
+
+// Len returns skiplist length.
+func (s *SafeSkipList) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sl.Len()
+}
+
+// Level returns skiplist level.
+func (s *SafeSkipList) Level() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sl.Level()
+}
+
+// Range calls f on each item in order until f returns false, the read lock
+// is held during the whole traversal, so f must not modify the skiplist.
+func (s *SafeSkipList) Range(f func(item Item) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	iter := s.sl.NewIterator(nil)
+	for iter.Next() {
+		if !f(iter.Item()) {
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSafeSkipList(t *testing.T) {
+	s := NewSafe(16)
+	Must(t, s.First() == nil)
+	var wg sync.WaitGroup
+	n, workers := 1024, 8
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += workers {
+				s.Put(Int(i))
+				Must(t, s.Has(Int(i)))
+				Must(t, s.Level() >= 1)
+			}
+		}(w)
+	}
+	wg.Wait()
+	Must(t, s.Len() == n)
+	Must(t, s.First() == Int(0))
+	Must(t, s.Get(Int(n-1)) == Int(n-1))
+	i := 0
+	s.Range(func(item Item) bool {
+		Must(t, item == Int(i))
+		i++
+		return i < 10
+	})
+	Must(t, i == 10)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += workers {
+				Must(t, s.Delete(Int(i)) == Int(i))
+			}
+		}(w)
+	}
+	wg.Wait()
+	Must(t, s.Len() == 0)
+	s.Put(Int(1))
+	s.Clear()
+	Must(t, s.Len() == 0)
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Chao Wang <hit9@icloud.com>.
+
+package skiplist
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNewSecure(t *testing.T) {
+	sl := NewSecure(16)
+	n := 10000
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.Len() == n)
+	Must(t, sl.Validate() == nil)
+	Must(t, math.Abs(sl.MeanLevel()-1/(1-FactorP)) < 0.1)
+	for i := 0; i < n; i += 2 {
+		Must(t, sl.Delete(Int(i)) == Int(i))
+	}
+	Must(t, sl.Validate() == nil)
+}
+
+func TestNewSecureDerived(t *testing.T) {
+	sl := NewSecure(16)
+	for i := 0; i < 100; i++ {
+		sl.Put(Int(i))
+	}
+	other := NewSecure(16)
+	other.Put(Int(1))
+	idx := sl.AddSecondaryIndex(func(a, b Item) bool { return b.Less(a) })
+	left, right := sl.Clone().Split(Int(50))
+	for _, c := range []*SkipList{sl.Clone(), left, right, idx,
+		Intersection(sl, other), Union(sl, other), Difference(sl, other)} {
+		src, ok := c.rand.(*secureSource)
+		Must(t, ok && src != sl.rand)
+	}
+}
+
+func TestSecureSourceIntn(t *testing.T) {
+	s := &secureSource{off: secureBufSize}
+	counts := make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		counts[s.Intn(3)]++
+	}
+	for _, c := range counts {
+		Must(t, c > 800 && c < 1200)
+	}
+}
+
+func BenchmarkPutSecure(b *testing.B) {
+	sl := NewSecure(50)
+	for i := 0; i < b.N; i++ {
+		sl.Put(Int(i))
+	}
+}
@@ -3,9 +3,17 @@
 package skiplist
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/bits"
 	"math/rand"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"unsafe"
 )
 
 // Must asserts the given value is True for testing.
@@ -16,6 +24,43 @@ func Must(t *testing.T, v bool) {
 	}
 }
 
+func TestEqualItems(t *testing.T) {
+	// a == b if neither a < b nor b < a.
+	Must(t, Equal(Int(1), Int(1)))
+	Must(t, !Equal(Int(1), Int(2)))
+	Must(t, !Equal(Int(2), Int(1)))
+	// Only the order matters.
+	Must(t, Equal(scoreItem{1, "a"}, scoreItem{1, "b"}))
+	Must(t, !Equal(scoreItem{1, "a"}, scoreItem{2, "a"}))
+}
+
+func TestFloat64(t *testing.T) {
+	sl := New(8)
+	for _, f := range []float64{2.5, -1, math.NaN(), math.Inf(1), 0.1} {
+		sl.Put(Float64(f))
+	}
+	Must(t, sl.Len() == 5)
+	Must(t, sl.Get(Float64(0.1)) == Float64(0.1))
+	Must(t, sl.Get(Float64(0.2)) == nil)
+	Must(t, sl.Has(Float64(math.NaN())))
+	Must(t, math.IsNaN(float64(sl.First().(Float64))))
+	Must(t, sl.Last() == Float64(math.Inf(1)))
+	Must(t, sl.Rank(Float64(-1)) == 1)
+	Must(t, sl.Validate() == nil)
+}
+
+func TestString(t *testing.T) {
+	sl := New(8)
+	for _, s := range []string{"pear", "apple", "", "fig"} {
+		sl.Put(String(s))
+	}
+	Must(t, sl.Get(String("fig")) == String("fig"))
+	Must(t, sl.Get(String("kiwi")) == nil)
+	Must(t, sl.First() == String(""))
+	Must(t, sl.Last() == String("pear"))
+	Must(t, sl.Rank(String("fig")) == 2)
+}
+
 func TestFirst(t *testing.T) {
 	sl := New(4)
 	Must(t, sl.First() == nil)
@@ -28,6 +73,210 @@ func TestFirst(t *testing.T) {
 	Must(t, sl.First() == nil)
 }
 
+func TestFirstUnique(t *testing.T) {
+	sl := New(8)
+	item, unique := sl.FirstUnique()
+	Must(t, item == nil && !unique)
+	sl.Put(scoreItem{2, "a"})
+	item, unique = sl.FirstUnique()
+	Must(t, item.(scoreItem).value == "a" && unique)
+	sl.Put(scoreItem{3, "b"})
+	item, unique = sl.FirstUnique()
+	Must(t, item.(scoreItem).value == "a" && unique)
+	sl.Put(scoreItem{2, "c"})
+	item, unique = sl.FirstUnique()
+	Must(t, item.(scoreItem).value == "c" && !unique)
+	sl.PopFirst()
+	_, unique = sl.FirstUnique()
+	Must(t, unique)
+}
+
+func TestLast(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.Last() == nil)
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	Must(t, equal(sl.Last(), Int(n-1)))
+	sl.Delete(Int(n - 1))
+	Must(t, equal(sl.Last(), Int(n-2)))
+	sl.Clear()
+	Must(t, sl.Last() == nil)
+}
+
+func TestMinMax(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.Min() == nil)
+	Must(t, sl.Max() == nil)
+	for i := 0; i < 1024; i++ {
+		sl.Put(Int(rand.Int()))
+		var min, max Item
+		iter := sl.NewIterator(nil)
+		for iter.Next() {
+			if min == nil {
+				min = iter.Item()
+			}
+			max = iter.Item()
+		}
+		Must(t, sl.Min() == min)
+		Must(t, sl.Max() == max)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	meanLevel := func(sl *SkipList) float64 {
+		total := 0
+		for n := sl.head.levels[0].forward; n != nil; n = n.levels[0].forward {
+			total += len(n.levels)
+		}
+		return float64(total) / float64(sl.Len())
+	}
+	low, high := NewWithOptions(32, 0.25, 1), NewWithOptions(32, 0.75, 1)
+	for i := 0; i < 1024*10; i++ {
+		low.Put(Int(i))
+		high.Put(Int(i))
+	}
+	// The mean level is 1/(1-p).
+	Must(t, meanLevel(low) > 1.2 && meanLevel(low) < 1.45)
+	Must(t, meanLevel(high) > 3.5 && meanLevel(high) < 4.5)
+	Must(t, low.Clone().factorP == 0.25)
+	for _, p := range []float64{0, 1, -0.5, 2} {
+		func() {
+			defer func() { Must(t, recover() != nil) }()
+			NewWithOptions(8, p, 1)
+		}()
+	}
+}
+
+func TestBuildFromSorted(t *testing.T) {
+	Must(t, BuildFromSorted(8, nil).Len() == 0)
+	n := 1024
+	items := make([]Item, n)
+	for i := range items {
+		items[i] = Int(i / 2)
+	}
+	sl := BuildFromSorted(8, items)
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.Len() == n)
+	iter := sl.NewIterator(nil)
+	for i := 0; iter.Next(); i++ {
+		Must(t, iter.Item() == items[i])
+	}
+	for i := 0; i < n; i += 2 {
+		Must(t, sl.Rank(items[i]) == i)
+	}
+	Must(t, equal(sl.Last(), Int(n/2-1)))
+	sl.Put(Int(n))
+	Must(t, sl.Rank(Int(n)) == n)
+	defer func() { Must(t, recover() != nil) }()
+	BuildFromSorted(8, []Item{Int(2), Int(1)})
+}
+
+func TestNodeLevels(t *testing.T) {
+	for level := 1; level <= 8; level++ {
+		n := newNode(level, Int(level))
+		Must(t, len(n.levels) == level && cap(n.levels) == level)
+		Must(t, n.item == Int(level))
+	}
+	// Both shallow and deep nodes.
+	sl := NewWithOptions(10, 0.8, 1)
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	stats := sl.Stats()
+	Must(t, stats.PerLevel[0] > stats.PerLevel[4] && stats.PerLevel[5] > 0)
+	for i := 0; i < sl.Level(); i++ {
+		var prev Item
+		for m := sl.head.levels[i].forward; m != nil; m = m.levels[i].forward {
+			Must(t, prev == nil || prev.Less(m.item))
+			Must(t, sl.Rank(m.item) == int(m.item.(Int)))
+			prev = m.item
+		}
+	}
+}
+
+// seqSource is a RandSource repeating a fixed sequence.
+type seqSource struct {
+	seq []int
+	i   int
+}
+
+func (src *seqSource) Intn(n int) int {
+	v := src.seq[src.i%len(src.seq)] % n
+	src.i++
+	return v
+}
+
+func TestWithRandSource(t *testing.T) {
+	// Levels: 1, 3, 2, 1, 3, 2, ...
+	up, stay := 0, 0xffff
+	build := func() *SkipList {
+		sl := New(8, WithRandSource(&seqSource{seq: []int{stay, up, up, stay, up, stay}}))
+		for i := 0; i < 30; i++ {
+			sl.Put(Int(i))
+		}
+		return sl
+	}
+	a, b := build(), build()
+	Must(t, a.Level() == 3)
+	for i := 0; i < 3; i++ {
+		m, n := a.head.levels[i].forward, b.head.levels[i].forward
+		count := 0
+		for m != nil && n != nil {
+			Must(t, m.item == n.item)
+			Must(t, len(m.levels) == len(n.levels))
+			Must(t, len(m.levels) == []int{1, 3, 2}[int(m.item.(Int))%3])
+			m, n = m.levels[i].forward, n.levels[i].forward
+			count++
+		}
+		Must(t, m == nil && n == nil)
+		Must(t, count == []int{30, 20, 10}[i])
+	}
+}
+
+func TestNewAutoLevel(t *testing.T) {
+	sl := NewAutoLevel(3)
+	Must(t, sl.MaxLevel() == 3)
+	n := 1000000
+	maxLevel := sl.MaxLevel()
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+		if sl.MaxLevel() != maxLevel || i < 10 {
+			maxLevel = sl.MaxLevel()
+			Must(t, sl.Len() <= 1<<uint(maxLevel))
+			for k := 0; k < 100; k++ {
+				j := rand.Intn(i + 1)
+				Must(t, sl.Get(Int(j)) == Int(j))
+				Must(t, sl.Rank(Int(j)) == j)
+			}
+			Must(t, !sl.Has(Int(i+1)))
+		}
+	}
+	Must(t, sl.MaxLevel() == 20)
+	Must(t, sl.Level() <= sl.MaxLevel())
+	for k := 0; k < 1000; k++ {
+		j := rand.Intn(n)
+		Must(t, sl.Get(Int(j)) == Int(j))
+	}
+	Must(t, sl.Clone().growAt == sl.growAt)
+}
+
+func TestReversed(t *testing.T) {
+	sl := New(8)
+	n := 100
+	for _, i := range rand.Perm(n) {
+		sl.Put(Reversed{Int(i)})
+	}
+	Must(t, sl.First().(Reversed).Item == Int(n-1))
+	Must(t, sl.Last().(Reversed).Item == Int(0))
+	Must(t, sl.Has(Reversed{Int(5)}))
+	for i := n - 1; i >= 0; i-- {
+		Must(t, sl.PopFirst().(Reversed).Item == Int(i))
+	}
+}
+
 func TestPut(t *testing.T) {
 	sl := New(16)
 	n := 1024 * 10
@@ -41,6 +290,443 @@ func TestPut(t *testing.T) {
 	}
 }
 
+type scoreItem struct {
+	score int
+	value string
+}
+
+func (item scoreItem) Less(than Item) bool {
+	return item.score < than.(scoreItem).score
+}
+
+func TestPutAllowDup(t *testing.T) {
+	sl := New(8)
+	sl.PutAllowDup(scoreItem{2, "x"})
+	sl.PutAllowDup(scoreItem{1, "a"})
+	sl.PutAllowDup(scoreItem{1, "b"})
+	sl.PutAllowDup(scoreItem{0, "y"})
+	sl.PutAllowDup(scoreItem{1, "c"})
+	Must(t, sl.Len() == 5)
+	var values []string
+	iter := sl.NewIterator(nil)
+	for iter.Next() {
+		values = append(values, iter.Item().(scoreItem).value)
+	}
+	Must(t, len(values) == 5)
+	Must(t, values[0] == "y" && values[4] == "x")
+	Must(t, values[1] == "a" && values[2] == "b" && values[3] == "c")
+	// Get and Delete work on the first one.
+	Must(t, sl.Get(scoreItem{score: 1}).(scoreItem).value == "a")
+	Must(t, sl.Delete(scoreItem{score: 1}).(scoreItem).value == "a")
+	Must(t, sl.Get(scoreItem{score: 1}).(scoreItem).value == "b")
+	// Put goes in front of the equals.
+	sl.Put(scoreItem{1, "d"})
+	Must(t, sl.Get(scoreItem{score: 1}).(scoreItem).value == "d")
+	Must(t, sl.Len() == 5)
+}
+
+func TestWithStableOrder(t *testing.T) {
+	sl := New(8, WithStableOrder())
+	want := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for i, v := range want {
+		sl.Put(scoreItem{i % 2, v})
+	}
+	var values []string
+	sl.ForEach(func(item Item) bool {
+		values = append(values, item.(scoreItem).value)
+		return true
+	})
+	Must(t, strings.Join(values, "") == "acegbdfh")
+	Must(t, sl.Get(scoreItem{score: 1}).(scoreItem).value == "b")
+	Must(t, sl.Validate() == nil)
+	// UpdateKey moves behind the equals too, in place or not.
+	sl = New(8, WithStableOrder())
+	for i := 1; i <= 6; i++ {
+		sl.Put(scoreItem{i, fmt.Sprint(i)})
+	}
+	for _, i := range []int{4, 6, 1, 3, 2} {
+		Must(t, sl.UpdateKey(scoreItem{score: i}, scoreItem{5, fmt.Sprint(i)}))
+	}
+	values = nil
+	sl.ForEach(func(item Item) bool {
+		values = append(values, item.(scoreItem).value)
+		return true
+	})
+	Must(t, strings.Join(values, "") == "546132")
+	Must(t, sl.Validate() == nil)
+	// PutNeighbors too.
+	sl = New(8, WithStableOrder())
+	sl.Put(scoreItem{1, "1"})
+	pred, succ := sl.PutNeighbors(scoreItem{1, "2"})
+	Must(t, pred.(scoreItem).value == "1" && succ == nil)
+	sl.Put(scoreItem{1, "3"})
+	values = nil
+	sl.ForEach(func(item Item) bool {
+		values = append(values, item.(scoreItem).value)
+		return true
+	})
+	Must(t, strings.Join(values, "") == "123")
+}
+
+func TestPutAppend(t *testing.T) {
+	sl := NewAutoLevel(2)
+	n := 1024
+	for i := 0; i < n; i++ {
+		Must(t, sl.PutAppend(Int(i/2)) == nil)
+	}
+	Must(t, sl.PutAppend(Int(0)) != nil)
+	Must(t, sl.Len() == n)
+	Must(t, sl.MaxLevel() > 2)
+	Must(t, sl.Validate() == nil)
+	for i := 0; i < n; i++ {
+		Must(t, sl.GetByRank(i) == Int(i/2))
+	}
+	// Mixed with other changes.
+	sl = New(8)
+	for i := 0; i < n; i++ {
+		Must(t, sl.PutAppend(Int(i)) == nil)
+		switch i % 7 {
+		case 3:
+			sl.Delete(Int(i))
+		case 5:
+			sl.Put(Int(i))
+		case 6:
+			sl.PopLast()
+		}
+	}
+	Must(t, sl.Validate() == nil)
+	sl.Truncate(10)
+	Must(t, sl.PutAppend(Int(n)) == nil)
+	Must(t, sl.Validate() == nil)
+	sl.Clear()
+	Must(t, sl.PutAppend(Int(0)) == nil)
+	sl.PopFirstN(1)
+	Must(t, sl.PutAppend(Int(1)) == nil)
+	Must(t, sl.Len() == 1 && sl.Validate() == nil)
+	// Equal items are appended behind.
+	a, b := scoreItem{1, "a"}, scoreItem{1, "b"}
+	sl = New(4)
+	Must(t, sl.PutAppend(a) == nil && sl.PutAppend(b) == nil)
+	Must(t, sl.First() == a && sl.Last() == b)
+}
+
+func TestPutAppendUpdateOnEqual(t *testing.T) {
+	sl := New(8, WithUpdateOnEqual())
+	Must(t, sl.PutAppend(Int(1)) == nil)
+	Must(t, sl.PutAppend(Int(1)) == nil)
+	Must(t, sl.Len() == 1)
+	sl = New(8, WithUpdateOnEqual())
+	Must(t, sl.PutAppend(weightItem{1, 1}) == nil)
+	Must(t, sl.PutAppend(weightItem{1, 2}) == nil)
+	Must(t, sl.PutAppend(weightItem{2, 1}) == nil)
+	Must(t, sl.Len() == 2 && sl.Get(weightItem{key: 1}).(weightItem).weight == 2)
+	Must(t, sl.PrefixSum(weightItem{key: 3}) == 3)
+	Must(t, sl.Validate() == nil)
+}
+
+func TestReplace(t *testing.T) {
+	sl := New(8)
+	old, replaced := sl.Replace(scoreItem{1, "a"})
+	Must(t, old == nil && !replaced)
+	sl.Put(scoreItem{2, "b"})
+	sl.Put(scoreItem{0, "c"})
+	Must(t, sl.Len() == 3)
+	old, replaced = sl.Replace(scoreItem{1, "d"})
+	Must(t, replaced)
+	Must(t, old.(scoreItem).value == "a")
+	Must(t, sl.Get(scoreItem{score: 1}).(scoreItem).value == "d")
+	Must(t, sl.Len() == 3)
+	old, replaced = sl.Replace(scoreItem{3, "e"})
+	Must(t, old == nil && !replaced)
+	Must(t, sl.Len() == 4)
+	Must(t, sl.Last().(scoreItem).value == "e")
+}
+
+// plainString orders only via a comparator.
+type plainString string
+
+func (s plainString) Less(than Item) bool {
+	panic("plainString: Less called")
+}
+
+func TestNewWithComparator(t *testing.T) {
+	sl := NewWithComparator(8, func(a, b Item) int {
+		x, y := a.(plainString), b.(plainString)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	})
+	words := []plainString{"pear", "apple", "fig", "kiwi", "banana"}
+	for _, w := range words {
+		sl.Put(w)
+	}
+	Must(t, sl.Len() == 5)
+	Must(t, sl.Has(plainString("fig")))
+	Must(t, !sl.Has(plainString("plum")))
+	Must(t, sl.Rank(plainString("kiwi")) == 3)
+	Must(t, sl.First() == plainString("apple"))
+	Must(t, sl.Delete(plainString("apple")) == plainString("apple"))
+	Must(t, sl.Delete(plainString("apple")) == nil)
+	iter := sl.NewIterator(plainString("c"))
+	var got []Item
+	for iter.Next() {
+		got = append(got, iter.Item())
+	}
+	Must(t, len(got) == 3)
+	Must(t, got[0] == plainString("fig") && got[2] == plainString("pear"))
+	Must(t, sl.Clone().Has(plainString("banana")))
+	// A nil cmp falls back to Less.
+	sl = NewWithComparator(8, nil)
+	sl.Put(Int(2))
+	sl.Put(Int(1))
+	Must(t, sl.First() == Int(1))
+}
+
+func TestNewWithPool(t *testing.T) {
+	sl := NewWithPool(8)
+	n := 1024
+	for k := 0; k < 4; k++ {
+		for _, i := range rand.Perm(n) {
+			sl.Put(Int(i))
+		}
+		for i := 0; i < n; i++ {
+			Must(t, sl.Rank(Int(i)) == i)
+		}
+		for i := 0; i < n; i += 2 {
+			Must(t, sl.Delete(Int(i)) == Int(i))
+		}
+		Must(t, sl.PopFirst() == Int(1))
+		Must(t, sl.PopLast() == Int(n-1))
+		Must(t, sl.Validate() == nil)
+		Must(t, sl.DeleteRange(nil, nil) == n/2-2)
+		Must(t, sl.Len() == 0)
+	}
+	Must(t, sl.Clone().pools != nil)
+}
+
+func TestGetOrPut(t *testing.T) {
+	sl := New(8)
+	actual, loaded := sl.GetOrPut(scoreItem{1, "a"})
+	Must(t, !loaded && actual.(scoreItem).value == "a")
+	actual, loaded = sl.GetOrPut(scoreItem{2, "b"})
+	Must(t, !loaded && actual.(scoreItem).value == "b")
+	actual, loaded = sl.GetOrPut(scoreItem{1, "c"})
+	Must(t, loaded && actual.(scoreItem).value == "a")
+	Must(t, sl.Len() == 2)
+	Must(t, sl.Get(scoreItem{score: 1}).(scoreItem).value == "a")
+}
+
+func TestWithUpdateOnEqual(t *testing.T) {
+	sl := New(8, WithUpdateOnEqual())
+	for i, v := range []string{"a", "b", "c"} {
+		sl.Put(scoreItem{1, v})
+		sl.Put(scoreItem{i + 2, v})
+	}
+	Must(t, sl.Len() == 4)
+	Must(t, sl.Get(scoreItem{score: 1}) == scoreItem{1, "c"})
+	Must(t, sl.Validate() == nil)
+	// Kept by copies.
+	c := sl.Clone()
+	c.Put(scoreItem{1, "d"})
+	Must(t, c.Len() == 4 && c.First() == scoreItem{1, "d"})
+}
+
+func TestWithLevelOf(t *testing.T) {
+	// Trailing zeros of a hash, which are geometric with factorP 0.5.
+	levelOf := func(item Item) int {
+		h := uint32(item.(Int)) * 2654435761
+		return 1 + bits.TrailingZeros32(h|1<<15)
+	}
+	layout := func() string {
+		sl := New(16, WithLevelOf(levelOf))
+		for _, i := range rand.Perm(1000) {
+			sl.Put(Int(i))
+		}
+		Must(t, sl.Validate() == nil)
+		var buf bytes.Buffer
+		sl.Print(&buf)
+		return buf.String()
+	}
+	Must(t, layout() == layout())
+	// Bad level
+	sl := New(4, WithLevelOf(func(Item) int { return 5 }))
+	defer func() {
+		Must(t, recover() != nil)
+	}()
+	sl.Put(Int(1))
+}
+
+// lessEqualItem implements Less with <= by mistake.
+type lessEqualItem int
+
+func (item lessEqualItem) Less(than Item) bool { return item <= than.(lessEqualItem) }
+
+func TestWithStrictCheck(t *testing.T) {
+	mustPanic := func(f func()) {
+		defer func() {
+			Must(t, recover() != nil)
+		}()
+		f()
+	}
+	sl := New(8, WithStrictCheck())
+	mustPanic(func() { sl.Put(lessEqualItem(1)) })
+	mustPanic(func() { sl.PutAppend(lessEqualItem(1)) })
+	// Not strict between neighbors only.
+	var cmp func(a, b Item) int
+	sl = New(8, WithStrictCheck())
+	sl.cmp = func(a, b Item) int { return cmp(a, b) }
+	cmp = func(a, b Item) int { return int(a.(Int) - b.(Int)) }
+	sl.Put(Int(1))
+	sl.Put(Int(3))
+	cmp = func(a, b Item) int {
+		if a != b && (a == Int(2) || b == Int(2)) {
+			return -1 // 2 < 3 < 2
+		}
+		return int(a.(Int) - b.(Int))
+	}
+	mustPanic(func() { sl.Put(Int(2)) })
+	// Fine with a strict Less.
+	sl = New(8, WithStrictCheck())
+	for i := 0; i < 100; i++ {
+		sl.Put(Int(rand.Intn(10)))
+		sl.PutAllowDup(Int(rand.Intn(10)))
+	}
+	Must(t, sl.Len() == 200)
+}
+
+func TestPutFromChannel(t *testing.T) {
+	sl := New(8)
+	ch := make(chan Item)
+	n := 100
+	go func() {
+		for _, i := range rand.Perm(n) {
+			ch <- Int(i)
+		}
+		close(ch)
+	}()
+	Must(t, sl.PutFromChannel(context.Background(), ch) == nil)
+	Must(t, sl.Len() == n)
+	for i := 0; i < n; i++ {
+		Must(t, sl.GetByRank(i) == Int(i))
+	}
+	// Canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	Must(t, sl.PutFromChannel(ctx, make(chan Item)) == context.Canceled)
+}
+
+func TestPutNeighbors(t *testing.T) {
+	sl := New(8)
+	pred, succ := sl.PutNeighbors(Int(5))
+	Must(t, pred == nil && succ == nil)
+	pred, succ = sl.PutNeighbors(Int(1))
+	Must(t, pred == nil && succ == Int(5))
+	pred, succ = sl.PutNeighbors(Int(9))
+	Must(t, pred == Int(5) && succ == nil)
+	pred, succ = sl.PutNeighbors(Int(3))
+	Must(t, pred == Int(1) && succ == Int(5))
+	// In front of the equal one.
+	pred, succ = sl.PutNeighbors(Int(3))
+	Must(t, pred == Int(1) && succ == Int(3))
+	Must(t, sl.Len() == 5)
+	Must(t, sl.Validate() == nil)
+	// Overwrites the equal one WithUpdateOnEqual.
+	sl = New(8, WithUpdateOnEqual())
+	sl.Put(scoreItem{1, "a"})
+	sl.Put(scoreItem{2, "b"})
+	sl.Put(scoreItem{3, "c"})
+	pred, succ = sl.PutNeighbors(scoreItem{2, "x"})
+	Must(t, pred.(scoreItem).value == "a" && succ.(scoreItem).value == "c")
+	Must(t, sl.Len() == 3 && sl.Get(scoreItem{score: 2}).(scoreItem).value == "x")
+	pred, succ = sl.PutNeighbors(scoreItem{4, "d"})
+	Must(t, pred.(scoreItem).value == "c" && succ == nil && sl.Len() == 4)
+	Must(t, sl.Validate() == nil)
+}
+
+func TestPutIfAbsent(t *testing.T) {
+	sl := New(8)
+	a, b := scoreItem{1, "a"}, scoreItem{1, "b"}
+	Must(t, sl.PutIfAbsent(a))
+	for i := 0; i < 10; i++ {
+		Must(t, !sl.PutIfAbsent(b))
+		Must(t, !sl.PutIfAbsent(a))
+	}
+	Must(t, sl.Len() == 1 && sl.Get(b) == a)
+	Must(t, sl.PutIfAbsent(scoreItem{2, "c"}))
+	Must(t, sl.Len() == 2)
+}
+
+func TestUpdateKey(t *testing.T) {
+	sl := New(8)
+	Must(t, !sl.UpdateKey(Int(1), Int(2)))
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(scoreItem{i * 10, fmt.Sprint(i)})
+	}
+	Must(t, !sl.UpdateKey(scoreItem{score: 5}, scoreItem{score: 6}))
+	// Move forward
+	Must(t, sl.UpdateKey(scoreItem{score: 30}, scoreItem{555, "x"}))
+	Must(t, !sl.Has(scoreItem{score: 30}))
+	Must(t, sl.Rank(scoreItem{score: 555}) == 55)
+	// Move backward
+	Must(t, sl.UpdateKey(scoreItem{score: 900}, scoreItem{-1, "y"}))
+	Must(t, sl.First().(scoreItem).value == "y")
+	// Stay in place
+	Must(t, sl.UpdateKey(scoreItem{score: 500}, scoreItem{505, "z"}))
+	Must(t, sl.Rank(scoreItem{score: 505}) == 50)
+	// Onto an equal key, in front of it.
+	Must(t, sl.UpdateKey(scoreItem{score: 10}, scoreItem{20, "w"}))
+	Must(t, sl.Get(scoreItem{score: 20}).(scoreItem).value == "w")
+	Must(t, sl.Len() == n)
+	Must(t, sl.Validate() == nil)
+	iter := sl.NewIterator(nil)
+	var prev Item
+	for iter.Next() {
+		Must(t, prev == nil || !iter.Item().Less(prev))
+		prev = iter.Item()
+	}
+}
+
+func TestUpdateKeyUpdateOnEqual(t *testing.T) {
+	sl := New(8, WithUpdateOnEqual())
+	sl.Put(scoreItem{1, "1"})
+	sl.Put(scoreItem{2, "2"})
+	sl.Put(scoreItem{4, "4"})
+	// Onto the next key, which stays put otherwise.
+	Must(t, sl.UpdateKey(scoreItem{score: 1}, scoreItem{2, "9"}))
+	Must(t, sl.Len() == 2 && sl.Get(scoreItem{score: 2}).(scoreItem).value == "9")
+	// Onto a key further.
+	Must(t, sl.UpdateKey(scoreItem{score: 2}, scoreItem{4, "8"}))
+	Must(t, sl.Len() == 1 && sl.First().(scoreItem).value == "8")
+	// In place.
+	Must(t, sl.UpdateKey(scoreItem{score: 4}, scoreItem{3, "7"}))
+	Must(t, sl.Len() == 1 && sl.First() == scoreItem{3, "7"})
+	Must(t, sl.Validate() == nil)
+}
+
+func TestPutBounded(t *testing.T) {
+	sl := New(8)
+	var seen []int
+	for i := 0; i < 1024; i++ {
+		v := rand.Intn(1 << 20)
+		seen = append(seen, v)
+		evicted := sl.PutBounded(Int(v), 10)
+		Must(t, (evicted == nil) == (i < 10))
+		Must(t, evicted == nil || !evicted.Less(sl.Last()))
+		Must(t, sl.Len() == len(seen) || sl.Len() == 10)
+	}
+	sort.Ints(seen)
+	for i, v := range intSlice(sl) {
+		Must(t, v == seen[i])
+	}
+	// The item itself is evicted.
+	Must(t, sl.PutBounded(Int(1<<20), 10) == Int(1<<20))
+}
+
 func TestGet(t *testing.T) {
 	sl := New(16)
 	n := 1024 * 10
@@ -54,6 +740,72 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestFloorCeil(t *testing.T) {
+	sl := New(8)
+	Must(t, sl.Floor(Int(1)) == nil)
+	Must(t, sl.Ceil(Int(1)) == nil)
+	n := 100
+	for i := 1; i <= n; i++ {
+		sl.Put(Int(i * 10))
+	}
+	// Exact matches
+	Must(t, sl.Floor(Int(10)) == Int(10))
+	Must(t, sl.Ceil(Int(10)) == Int(10))
+	Must(t, sl.Floor(Int(500)) == Int(500))
+	Must(t, sl.Ceil(Int(500)) == Int(500))
+	// Between elements
+	Must(t, sl.Floor(Int(505)) == Int(500))
+	Must(t, sl.Ceil(Int(505)) == Int(510))
+	// Smaller than Min
+	Must(t, sl.Floor(Int(9)) == nil)
+	Must(t, sl.Ceil(Int(9)) == Int(10))
+	// Larger than Max
+	Must(t, sl.Floor(Int(n*10+1)) == Int(n*10))
+	Must(t, sl.Ceil(Int(n*10+1)) == nil)
+}
+
+func TestClosest(t *testing.T) {
+	dist := func(a, b Item) float64 { return math.Abs(float64(a.(Int) - b.(Int))) }
+	sl := New(8)
+	Must(t, sl.Closest(Int(1), dist) == nil)
+	for i := 1; i <= 100; i++ {
+		sl.Put(Int(i * 10))
+	}
+	Must(t, sl.Closest(Int(500), dist) == Int(500))
+	Must(t, sl.Closest(Int(502), dist) == Int(500))
+	Must(t, sl.Closest(Int(508), dist) == Int(510))
+	Must(t, sl.Closest(Int(505), dist) == Int(500)) // Tie
+	// Extremes
+	Must(t, sl.Closest(Int(-100), dist) == Int(10))
+	Must(t, sl.Closest(Int(2000), dist) == Int(1000))
+}
+
+func TestNeighbors(t *testing.T) {
+	sl := New(8)
+	pred, succ := sl.Neighbors(Int(1))
+	Must(t, pred == nil && succ == nil)
+	n := 100
+	for i := 1; i <= n; i++ {
+		sl.Put(Int(i * 10))
+	}
+	sl.Put(Int(500))
+	// Exact matches
+	pred, succ = sl.Neighbors(Int(500))
+	Must(t, pred == Int(490) && succ == Int(510))
+	// Between elements
+	pred, succ = sl.Neighbors(Int(505))
+	Must(t, pred == Int(500) && succ == Int(510))
+	// Extremes
+	pred, succ = sl.Neighbors(Int(10))
+	Must(t, pred == nil && succ == Int(20))
+	pred, succ = sl.Neighbors(Int(n * 10))
+	Must(t, pred == Int(n*10-10) && succ == nil)
+	pred, succ = sl.Neighbors(Int(9))
+	Must(t, pred == nil && succ == Int(10))
+	pred, succ = sl.Neighbors(Int(n*10 + 1))
+	Must(t, pred == Int(n*10) && succ == nil)
+}
+
 func TestDelete(t *testing.T) {
 	sl := New(16)
 	n := 1024 * 10
@@ -69,6 +821,328 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteMany(t *testing.T) {
+	sl := New(8)
+	Must(t, sl.DeleteMany(nil) == 0)
+	Must(t, sl.DeleteMany([]Item{Int(1)}) == 0)
+	n := 1024
+	for _, sorted := range []bool{true, false} {
+		for i := 0; i < n; i++ {
+			sl.Put(Int(i))
+		}
+		deleted := make(map[int]bool)
+		var items []Item
+		for i := 0; i < n; i++ {
+			if rand.Intn(3) == 0 {
+				deleted[i] = true
+				items = append(items, Int(i))
+			}
+		}
+		// Absent ones
+		items = append(items, Int(-1), Int(n))
+		if sorted {
+			sort.Slice(items, func(i, j int) bool { return items[i].Less(items[j]) })
+		} else {
+			rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		}
+		Must(t, sl.DeleteMany(items) == len(deleted))
+		Must(t, sl.Len() == n-len(deleted))
+		Must(t, sl.Validate() == nil)
+		for i := 0; i < n; i++ {
+			Must(t, sl.Has(Int(i)) == !deleted[i])
+		}
+		sl.Clear()
+	}
+	// Duplicates, one is deleted for each.
+	for i := 0; i < 3; i++ {
+		sl.Put(Int(1))
+	}
+	sl.Put(Int(2))
+	Must(t, sl.DeleteMany([]Item{Int(1), Int(1), Int(2), Int(2)}) == 3)
+	Must(t, sl.Len() == 1 && sl.First() == Int(1))
+	Must(t, sl.Validate() == nil)
+}
+
+func TestDeleteOk(t *testing.T) {
+	sl := New(8)
+	item, ok := sl.DeleteOk(Int(1))
+	Must(t, item == nil && !ok)
+	sl.Put(Int(1))
+	sl.Put(Int(2))
+	item, ok = sl.DeleteOk(Int(1))
+	Must(t, item == Int(1) && ok)
+	item, ok = sl.DeleteOk(Int(1))
+	Must(t, item == nil && !ok)
+	Must(t, sl.Len() == 1)
+}
+
+func TestRank(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.Rank(Int(1)) == -1)
+	n := 1024
+	for i := 0; i < n*4; i++ {
+		switch rand.Intn(4) {
+		case 0:
+			sl.Delete(Int(rand.Intn(n)))
+		case 1:
+			sl.PopFirst()
+		default:
+			sl.Put(Int(rand.Intn(n)))
+		}
+	}
+	var values []Item
+	iter := sl.NewIterator(nil)
+	for iter.Next() {
+		values = append(values, iter.Item())
+	}
+	Must(t, len(values) == sl.Len())
+	for i := 0; i < n; i++ {
+		rank := -1
+		for j, item := range values {
+			if equal(item, Int(i)) {
+				rank = j
+				break
+			}
+		}
+		Must(t, sl.Rank(Int(i)) == rank)
+	}
+}
+
+func TestGetByRank(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.GetByRank(0) == nil)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(Int(rand.Intn(n)))
+	}
+	for i := 0; i < n/2; i++ {
+		sl.Delete(Int(rand.Intn(n)))
+	}
+	Must(t, sl.GetByRank(-1) == nil)
+	Must(t, sl.GetByRank(sl.Len()) == nil)
+	k := 0
+	iter := sl.NewIterator(nil)
+	for iter.Next() {
+		Must(t, sl.GetByRank(k) == iter.Item())
+		k++
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.Quantile(0.5) == nil)
+	n := 1001
+	samples := make([]int, n)
+	for i := range samples {
+		samples[i] = rand.Intn(10000)
+		sl.Put(Int(samples[i]))
+	}
+	sort.Ints(samples)
+	for _, q := range []float64{0, 0.25, 0.5, 0.95, 0.99, 1} {
+		Must(t, sl.Quantile(q) == Int(samples[int(q*float64(n-1))]))
+	}
+	for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+		func() {
+			defer func() { Must(t, recover() != nil) }()
+			sl.Quantile(q)
+		}()
+	}
+}
+
+func TestAt(t *testing.T) {
+	sl := New(8)
+	Must(t, sl.At(0) == nil && sl.At(-1) == nil)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.At(0) == Int(0))
+	Must(t, sl.At(n-1) == Int(n-1))
+	Must(t, sl.At(-1) == Int(n-1))
+	Must(t, sl.At(-2) == Int(n-2))
+	Must(t, sl.At(-n) == Int(0))
+	Must(t, sl.At(n) == nil)
+	Must(t, sl.At(-n-1) == nil)
+}
+
+func TestDeleteByRank(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.DeleteByRank(0) == nil)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.DeleteByRank(-1) == nil)
+	Must(t, sl.DeleteByRank(n) == nil)
+	for sl.Len() > 0 {
+		l := sl.Len()
+		k := rand.Intn(l)
+		item := sl.GetByRank(k)
+		Must(t, sl.DeleteByRank(k) == item)
+		Must(t, sl.Len() == l-1)
+		Must(t, !sl.Has(item))
+	}
+	Must(t, sl.Validate() == nil)
+	// Duplicates, delete the last of the equals.
+	a, b := scoreItem{1, "a"}, scoreItem{1, "b"}
+	sl.PutAllowDup(a)
+	sl.PutAllowDup(b)
+	sl.Put(scoreItem{2, "c"})
+	Must(t, sl.DeleteByRank(1) == b)
+	Must(t, sl.Len() == 2 && sl.First() == a)
+	Must(t, sl.Validate() == nil)
+}
+
+type weightItem struct {
+	key    int
+	weight float64
+}
+
+func (item weightItem) Less(than Item) bool { return item.key < than.(weightItem).key }
+
+func (item weightItem) Weight() float64 { return item.weight }
+
+func TestPrefixSum(t *testing.T) {
+	sl := New(8)
+	Must(t, sl.PrefixSum(weightItem{key: 1}) == 0)
+	n := 1024
+	weights := make(map[int]float64)
+	for _, i := range rand.Perm(n) {
+		weights[i] = float64(rand.Intn(100))
+		sl.Put(weightItem{i, weights[i]})
+	}
+	for i := 0; i < n/4; i++ {
+		k := rand.Intn(n)
+		if sl.Delete(weightItem{key: k}) != nil {
+			delete(weights, k)
+		}
+		k = rand.Intn(n)
+		if _, ok := weights[k]; ok {
+			weights[k] = float64(rand.Intn(100))
+			sl.Replace(weightItem{k, weights[k]})
+		}
+	}
+	for _, item := range sl.PopFirstN(10) {
+		delete(weights, item.(weightItem).key)
+	}
+	Must(t, sl.Validate() == nil)
+	check := func(sl *SkipList) {
+		for i := -1; i <= n; i++ {
+			sum := 0.0
+			for k, w := range weights {
+				if k < i {
+					sum += w
+				}
+			}
+			Must(t, sl.PrefixSum(weightItem{key: i}) == sum)
+		}
+	}
+	check(sl)
+	check(sl.Clone())
+	sl = Concat(sl.Split(weightItem{key: n / 2}))
+	Must(t, sl.Validate() == nil)
+	check(sl)
+	Must(t, sl.PutAppend(weightItem{n, 1.5}) == nil)
+	weights[n] = 1.5
+	check(sl)
+	Must(t, sl.PrefixSum(weightItem{key: n + 1}) == sl.PrefixSum(weightItem{key: n})+1.5)
+	// Items not Weighted weigh 0.
+	sl = New(8)
+	sl.Put(Int(1))
+	Must(t, sl.PrefixSum(Int(2)) == 0)
+}
+
+func TestPrefixSumChangeInPlace(t *testing.T) {
+	sl := New(8)
+	for i := 0; i < 50; i++ {
+		Must(t, sl.PutAppend(weightItem{i, 1}) == nil)
+	}
+	sl.Replace(weightItem{48, 5})
+	for i := 50; i < 60; i++ {
+		Must(t, sl.PutAppend(weightItem{i, 1}) == nil)
+	}
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.UpdateKey(weightItem{key: 58}, weightItem{58, 3}))
+	for i := 60; i < 70; i++ {
+		Must(t, sl.PutAppend(weightItem{i, 1}) == nil)
+	}
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.PrefixSum(weightItem{key: 70}) == 76)
+}
+
+func TestRankRange(t *testing.T) {
+	sl := New(8)
+	lo, hi := sl.RankRange(Int(1))
+	Must(t, lo == -1 && hi == -1)
+	counts := map[int]int{1: 3, 2: 1, 5: 10, 7: 2}
+	for k, c := range counts {
+		for i := 0; i < c; i++ {
+			sl.PutAllowDup(Int(k))
+		}
+	}
+	pos := 0
+	for _, k := range []int{1, 2, 5, 7} {
+		lo, hi = sl.RankRange(Int(k))
+		Must(t, lo == pos && hi-lo == counts[k])
+		pos = hi
+	}
+	lo, hi = sl.RankRange(Int(3))
+	Must(t, lo == -1 && hi == -1)
+	lo, hi = sl.RankRange(Int(8))
+	Must(t, lo == -1 && hi == -1)
+}
+
+func TestCountRange(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.CountRange(nil, nil) == 0)
+	Must(t, sl.CountRange(Int(1), Int(2)) == 0)
+	n := 1024
+	var values []int
+	for i := 0; i < n; i++ {
+		v := rand.Intn(n)
+		sl.Put(Int(v))
+		values = append(values, v)
+	}
+	Must(t, sl.CountRange(nil, nil) == n)
+	for k := 0; k < 256; k++ {
+		lo, hi := rand.Intn(n+2)-1, rand.Intn(n+2)-1
+		count := 0
+		for _, v := range values {
+			if v >= lo && v < hi {
+				count++
+			}
+		}
+		Must(t, sl.CountRange(Int(lo), Int(hi)) == count)
+		count = 0
+		for _, v := range values {
+			if v < hi {
+				count++
+			}
+		}
+		Must(t, sl.CountRange(nil, Int(hi)) == count)
+		Must(t, sl.CountRange(Int(hi), nil) == n-count)
+	}
+}
+
+func TestAnyInRange(t *testing.T) {
+	sl := New(8)
+	Must(t, !sl.AnyInRange(nil, nil))
+	Must(t, !sl.AnyInRange(Int(1), Int(5)))
+	for i := 1; i <= 10; i++ {
+		sl.Put(Int(i * 10))
+	}
+	Must(t, sl.AnyInRange(nil, nil))
+	Must(t, sl.AnyInRange(Int(10), Int(11)))
+	Must(t, sl.AnyInRange(Int(15), Int(21)))
+	Must(t, !sl.AnyInRange(Int(11), Int(20)))
+	Must(t, !sl.AnyInRange(Int(20), Int(20)))
+	Must(t, !sl.AnyInRange(Int(30), Int(20)))
+	Must(t, sl.AnyInRange(nil, Int(11)))
+	Must(t, !sl.AnyInRange(nil, Int(10)))
+	Must(t, sl.AnyInRange(Int(100), nil))
+	Must(t, !sl.AnyInRange(Int(101), nil))
+}
+
 func TestPopFirst(t *testing.T) {
 	sl := New(3)
 	Must(t, sl.First() == nil)
@@ -118,21 +1192,625 @@ func TestPopFirst(t *testing.T) {
 	}
 }
 
-func TestClear(t *testing.T) {
-	sl := New(4)
-	sl.Put(Int(4))
-	sl.Put(Int(3))
-	sl.Put(Int(2))
-	sl.Put(Int(1))
-	Must(t, sl.Len() == 4)
-	sl.Clear()
-	Must(t, sl.Len() == 0)
-	Must(t, sl.head.item == nil)
-	Must(t, sl.First() == nil)
+func TestPopFirstN(t *testing.T) {
+	sl := New(8)
+	Must(t, len(sl.PopFirstN(3)) == 0)
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	c := sl.Clone()
+	next := 0
+	for sl.Len() > 0 {
+		k := rand.Intn(100)
+		items := sl.PopFirstN(k)
+		Must(t, len(items) == k || len(items) == c.Len() && k > c.Len())
+		for _, item := range items {
+			Must(t, item == c.PopFirst())
+			Must(t, item == Int(next))
+			next++
+		}
+		Must(t, sl.Len() == c.Len())
+		Must(t, sl.First() == c.First())
+		Must(t, sl.Validate() == nil)
+		Must(t, sl.Len() == 0 || sl.head.levels[sl.Level()-1].forward != nil)
+		for i := next; i < n; i += 37 {
+			Must(t, sl.Rank(Int(i)) == i-next)
+		}
+		iter := sl.NewReverseIterator(Int(next + 1))
+		Must(t, !iter.Prev() || iter.Item() == Int(next) && !iter.Prev())
+	}
+	Must(t, next == n)
 	Must(t, sl.Level() == 1)
+	Must(t, len(sl.PopFirstN(-1)) == 0)
 }
 
-func TestIteratorNil(t *testing.T) {
+func TestPopLast(t *testing.T) {
+	sl := New(8)
+	Must(t, sl.PopLast() == nil)
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	for i := n - 1; i >= 0; i-- {
+		Must(t, equal(sl.PopLast(), Int(i)))
+		Must(t, sl.Len() == i)
+		Must(t, sl.Validate() == nil)
+		if i > 0 {
+			Must(t, equal(sl.Last(), Int(i-1)))
+		}
+	}
+	Must(t, sl.PopLast() == nil)
+	Must(t, sl.Level() == 1)
+}
+
+func TestPopLastLevel(t *testing.T) {
+	sl := New(4)
+	// Level[0]: 1 -> 2 -> 3 -> nil
+	// Level[1]: 2 -> 3 -> nil
+	// Level[2]: 3 -> nil
+	n1, n2, n3 := newNode(1, Int(1)), newNode(2, Int(2)), newNode(3, Int(3))
+	sl.head.levels[0].forward, n1.levels[0].forward, n2.levels[0].forward = n1, n2, n3
+	sl.head.levels[1].forward, n2.levels[1].forward = n2, n3
+	sl.head.levels[2].forward = n3
+	n1.backward, n2.backward, n3.backward = sl.head, n1, n2
+	sl.head.levels[0].span, n1.levels[0].span, n2.levels[0].span = 1, 1, 1
+	sl.head.levels[1].span, n2.levels[1].span = 2, 1
+	sl.head.levels[2].span = 3
+	sl.level, sl.length = 3, 3
+	Must(t, equal(sl.PopLast(), Int(3)))
+	Must(t, sl.Len() == 2)
+	Must(t, sl.Level() == 2)
+	Must(t, n2.levels[0].forward == nil && n2.levels[1].forward == nil)
+	Must(t, equal(sl.Last(), Int(2)))
+	Must(t, equal(sl.PopLast(), Int(2)))
+	Must(t, sl.Level() == 1)
+	Must(t, equal(sl.Last(), Int(1)))
+}
+
+func TestDeleteRange(t *testing.T) {
+	sl := New(8)
+	Must(t, sl.DeleteRange(nil, nil) == 0)
+	n := 1024
+	for k := 0; k < 64; k++ {
+		for _, i := range rand.Perm(n) {
+			sl.Put(Int(i))
+		}
+		lo, hi := rand.Intn(n), rand.Intn(n)
+		var start, stop Item
+		if k%4 != 1 {
+			start = Int(lo)
+		} else {
+			lo = 0
+		}
+		if k%4 != 2 {
+			stop = Int(hi)
+		} else {
+			hi = n
+		}
+		count := 0
+		if lo < hi {
+			count = hi - lo
+		}
+		Must(t, sl.DeleteRange(start, stop) == count)
+		Must(t, sl.Len() == n-count)
+		Must(t, sl.Validate() == nil)
+		iter := sl.NewIterator(nil)
+		i := 0
+		for iter.Next() {
+			Must(t, int(iter.Item().(Int)) < lo || int(iter.Item().(Int)) >= hi)
+			Must(t, sl.Rank(iter.Item()) == i)
+			i++
+		}
+		Must(t, i == sl.Len())
+		Must(t, sl.Len() == 0 || sl.head.levels[sl.Level()-1].forward != nil)
+		sl.Clear()
+	}
+}
+
+func TestDeleteRangeFunc(t *testing.T) {
+	sl := New(8)
+	even := func(item Item) bool { return item.(Int)%2 == 0 }
+	Must(t, sl.DeleteRangeFunc(nil, nil, even) == 0)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.DeleteRangeFunc(Int(100), Int(200), even) == 50)
+	Must(t, sl.Len() == n-50)
+	Must(t, sl.Validate() == nil)
+	for i := 0; i < n; i++ {
+		Must(t, sl.Has(Int(i)) == (i < 100 || i >= 200 || i%2 == 1))
+	}
+	// Open bounds
+	Must(t, sl.DeleteRangeFunc(nil, Int(10), even) == 5)
+	Must(t, sl.DeleteRangeFunc(Int(1000), nil, even) == 12)
+	Must(t, sl.DeleteRangeFunc(nil, nil, func(Item) bool { return false }) == 0)
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.DeleteRangeFunc(nil, nil, func(Item) bool { return true }) == n-67)
+	Must(t, sl.Len() == 0)
+}
+
+func TestRetainFunc(t *testing.T) {
+	sl := NewWithPool(8)
+	Must(t, sl.RetainFunc(func(Item) bool { return false }) == 0)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(weightItem{i, float64(i % 10)})
+	}
+	Must(t, sl.RetainFunc(func(item Item) bool { return item.(weightItem).key%3 == 0 }) == n-(n+2)/3)
+	Must(t, sl.Len() == (n+2)/3)
+	Must(t, sl.Validate() == nil)
+	i := 0
+	sl.ForEach(func(item Item) bool {
+		Must(t, item.(weightItem).key == i)
+		i += 3
+		return true
+	})
+	Must(t, sl.PutAppend(weightItem{n, 1}) == nil)
+	Must(t, sl.Validate() == nil)
+	// All gone
+	Must(t, sl.RetainFunc(func(Item) bool { return false }) == (n+2)/3+1)
+	Must(t, sl.Len() == 0 && sl.Level() == 1)
+	Must(t, sl.Validate() == nil)
+}
+
+func TestTruncate(t *testing.T) {
+	sl := New(8)
+	sl.Truncate(3)
+	Must(t, sl.Len() == 0)
+	n := 1024
+	for _, k := range []int{n * 2, n, n - 1, 500, 100, 1, 0, -1} {
+		sl.Clear()
+		for _, i := range rand.Perm(n) {
+			sl.Put(Int(i))
+		}
+		sl.Truncate(k)
+		m := k
+		if m > n {
+			m = n
+		} else if m < 0 {
+			m = 0
+		}
+		Must(t, sl.Len() == m)
+		Must(t, sl.Validate() == nil)
+		for i, v := range intSlice(sl) {
+			Must(t, v == i)
+		}
+		if m > 0 {
+			Must(t, sl.Last() == Int(m-1))
+		}
+		sl.Put(Int(n))
+		Must(t, sl.Rank(Int(n)) == m)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for _, key := range []int{-1, 0, 1, 333, 511, 1023, 1024, 2000} {
+		sl := New(8)
+		n := 1024
+		for i := 0; i < n; i++ {
+			sl.Put(Int(i))
+		}
+		left, right := sl.Split(Int(key))
+		Must(t, left.Validate() == nil)
+		Must(t, right.Validate() == nil)
+		Must(t, left.Len()+right.Len() == n)
+		i := 0
+		left.ForEach(func(item Item) bool {
+			Must(t, item == Int(i) && i < key)
+			i++
+			return true
+		})
+		right.ForEach(func(item Item) bool {
+			Must(t, item == Int(i) && i >= key)
+			i++
+			return true
+		})
+		Must(t, i == n)
+		// Both work on.
+		left.Put(Int(key - 1))
+		right.Put(Int(key))
+		Must(t, left.Validate() == nil)
+		Must(t, right.Validate() == nil)
+	}
+	left, right := New(8).Split(Int(1))
+	Must(t, left.Len() == 0 && right.Len() == 0)
+}
+
+func TestConcat(t *testing.T) {
+	n := 1024
+	for _, key := range []int{0, 1, 333, 1023, 1024} {
+		sl := New(8)
+		for i := 0; i < n; i++ {
+			sl.Put(Int(i))
+		}
+		c := Concat(sl.Split(Int(key)))
+		Must(t, c.Validate() == nil)
+		Must(t, c.Len() == n)
+		for i := 0; i < n; i++ {
+			Must(t, c.GetByRank(i) == Int(i))
+		}
+	}
+	// Levels of b above a.
+	a, b := New(2), New(16)
+	for i := 0; i < n; i++ {
+		a.PutAppend(Int(i))
+		b.Put(Int(n + i))
+	}
+	level := b.Level()
+	Must(t, level > a.MaxLevel())
+	c := Concat(a, b)
+	Must(t, c == a && b.Len() == 0)
+	Must(t, c.Level() == level)
+	Must(t, c.Validate() == nil)
+	Must(t, c.Len() == 2*n)
+	i := 0
+	c.ForEach(func(item Item) bool {
+		Must(t, item == Int(i))
+		i++
+		return true
+	})
+	Must(t, i == 2*n)
+	// Empty a keeps its settings.
+	a, b = New(8, WithUpdateOnEqual()), New(8)
+	for i := 0; i < 10; i++ {
+		b.Put(Int(i))
+	}
+	c = Concat(a, b)
+	Must(t, c == a && a.Len() == 10 && b.Len() == 0)
+	Must(t, a.Validate() == nil && b.Validate() == nil)
+	a.Put(Int(3))
+	Must(t, a.Len() == 10 && a.First() == Int(0))
+	// Not disjoint
+	a, b = New(4), New(4)
+	a.Put(Int(1))
+	b.Put(Int(1))
+	defer func() {
+		Must(t, recover() != nil)
+	}()
+	Concat(a, b)
+}
+
+func TestClear(t *testing.T) {
+	sl := New(4)
+	sl.Put(Int(4))
+	sl.Put(Int(3))
+	sl.Put(Int(2))
+	sl.Put(Int(1))
+	Must(t, sl.Len() == 4)
+	sl.Clear()
+	Must(t, sl.Len() == 0)
+	Must(t, sl.head.item == nil)
+	Must(t, sl.First() == nil)
+	Must(t, sl.Last() == nil)
+	Must(t, sl.Level() == 1)
+	Must(t, !sl.NewIterator(nil).Next())
+	// Works again.
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.Len() == n)
+	for i := 0; i < n; i++ {
+		Must(t, sl.Rank(Int(i)) == i)
+	}
+	Must(t, sl.First() == Int(0))
+	Must(t, sl.Last() == Int(n-1))
+}
+
+func TestAddSecondaryIndex(t *testing.T) {
+	sl := New(8)
+	byValue := func(a, b Item) bool { return a.(scoreItem).value < b.(scoreItem).value }
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(scoreItem{i, fmt.Sprintf("%03d", n-i)})
+	}
+	idx := sl.AddSecondaryIndex(byValue)
+	check := func() {
+		Must(t, idx.Len() == sl.Len())
+		Must(t, idx.Validate() == nil)
+		values := sl.Values()
+		sort.Slice(values, func(i, j int) bool { return byValue(values[i], values[j]) })
+		i := 0
+		idx.ForEach(func(item Item) bool {
+			Must(t, item == values[i])
+			i++
+			return true
+		})
+	}
+	check()
+	Must(t, idx.First() == scoreItem{n - 1, "001"})
+	// Kept in sync
+	sl.Put(scoreItem{n, "000"})
+	sl.PutAllowDup(scoreItem{n, "001"}) // Equal by value
+	sl.Delete(scoreItem{score: 0})
+	sl.Replace(scoreItem{1, "zzz"})
+	sl.UpdateKey(scoreItem{score: 2}, scoreItem{2, "yyy"})
+	sl.PopFirstN(2)
+	sl.PopLast()
+	sl.Truncate(n - 20)
+	sl.RetainFunc(func(item Item) bool { return item.(scoreItem).score%5 != 0 })
+	sl.PutAppend(scoreItem{n * 2, "001"})
+	check()
+	Must(t, idx.Get(scoreItem{value: "001"}) != nil)
+	left, right := sl.Split(scoreItem{score: 50})
+	Must(t, right.Len() > 0)
+	check()
+	Concat(left, right)
+	check()
+	sl.Clear()
+	Must(t, idx.Len() == 0)
+}
+
+func TestWithKeepLevel(t *testing.T) {
+	sl := New(16, WithKeepLevel())
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	level := sl.Level()
+	for i := 0; i < n; i += 2 {
+		sl.Delete(Int(i))
+	}
+	sl.PopFirstN(n / 4)
+	sl.Truncate(n / 8)
+	_, right := sl.Split(Int(n / 2))
+	Must(t, sl.Level() == level && right.Level() == level)
+	Must(t, sl.Validate() == nil && right.Validate() == nil)
+	for i := 0; i < n; i++ {
+		sl.Delete(Int(i))
+	}
+	Must(t, sl.Len() == 0 && sl.Level() == level)
+	for i := 0; i < 10; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.Get(Int(5)) == Int(5) && sl.Rank(Int(5)) == 5)
+	sl.Compact()
+	Must(t, sl.Level() < level || sl.head.levels[level-1].forward != nil)
+	Must(t, sl.Validate() == nil)
+	// Lowered otherwise.
+	sl = New(16)
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	sl.Truncate(1)
+	Must(t, sl.Level() == len(sl.head.levels[0].forward.levels))
+	// Concat with empty levels on top of b.
+	a, b := New(16, WithKeepLevel()), New(16, WithKeepLevel())
+	for i := 0; i < 100; i++ {
+		a.Put(Int(i))
+		b.Put(Int(100 + i))
+	}
+	b.PopFirstN(99)
+	// Raised only by the node left in b, if it's higher.
+	level = a.Level()
+	if h := len(b.head.levels[0].forward.levels); h > level {
+		level = h
+	}
+	Must(t, Concat(a, b) == a && a.Len() == 101)
+	Must(t, a.Validate() == nil && a.Level() == level)
+	// RetainFunc keeps the level too.
+	a.RetainFunc(func(item Item) bool { return item.(Int)%10 == 0 })
+	Must(t, a.Validate() == nil && a.Level() == level)
+	for i := 1; i < 100; i += 10 {
+		a.Put(Int(i))
+	}
+	Must(t, a.Validate() == nil && a.Len() == 20)
+}
+
+func TestCompact(t *testing.T) {
+	sl := NewWithPool(16)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.PutAppend(Int(i))
+	}
+	// Delete the nodes above level 2.
+	iter := sl.NewIterator(nil)
+	for iter.Next() {
+		if iter.Level() > 2 {
+			iter.Remove()
+		}
+	}
+	sl.level = sl.maxLevel // Left high
+	sl.Compact()
+	Must(t, sl.Level() <= 2)
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.tail == nil)
+	iter = sl.NewIterator(nil)
+	for iter.Next() {
+		Must(t, sl.Get(iter.Item()) == iter.Item())
+	}
+	sl.Put(Int(n))
+	Must(t, sl.PutAppend(Int(n+1)) == nil)
+	Must(t, sl.Validate() == nil)
+}
+
+func TestClone(t *testing.T) {
+	sl := New(8)
+	Must(t, sl.Clone().Len() == 0)
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	c := sl.Clone()
+	Must(t, c.Validate() == nil)
+	Must(t, c.Len() == sl.Len())
+	Must(t, c.Level() == sl.Level())
+	Must(t, c.MaxLevel() == sl.MaxLevel())
+	// Same layout, but no node shared.
+	for i := 0; i < sl.Level(); i++ {
+		m, n := sl.head.levels[i].forward, c.head.levels[i].forward
+		for m != nil && n != nil {
+			Must(t, m != n)
+			Must(t, m.item == n.item)
+			Must(t, m.levels[i].span == n.levels[i].span)
+			m, n = m.levels[i].forward, n.levels[i].forward
+		}
+		Must(t, m == nil && n == nil)
+	}
+	for i := 0; i < n; i++ {
+		Must(t, c.Rank(Int(i)) == i)
+	}
+	// Mutate the clone.
+	c.Delete(Int(3))
+	c.Put(Int(n))
+	Must(t, sl.Has(Int(3)))
+	Must(t, !sl.Has(Int(n)))
+	Must(t, sl.Len() == n)
+	// Mutate the original.
+	sl.Delete(Int(5))
+	Must(t, c.Has(Int(5)))
+	Must(t, equal(c.PopLast(), Int(n)))
+	Must(t, equal(c.PopFirst(), Int(0)))
+	Must(t, c.Len() == n-2)
+	Must(t, sl.Len() == n-1)
+}
+
+func TestEqual(t *testing.T) {
+	a, b := New(8), New(16)
+	Must(t, a.Equal(b))
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		a.Put(Int(i))
+	}
+	for _, i := range rand.Perm(n) {
+		b.Put(Int(i))
+	}
+	Must(t, a.Equal(b) && b.Equal(a))
+	Must(t, a.Equal(a))
+	b.Delete(Int(5))
+	Must(t, !a.Equal(b) && !b.Equal(a))
+	b.Put(Int(n))
+	Must(t, !a.Equal(b) && !b.Equal(a))
+	Must(t, !a.Equal(New(8)))
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(8), New(8)
+	n := 512
+	for i := 0; i < n; i++ {
+		a.Put(Int(i * 2))
+		b.Put(Int(i * 3))
+	}
+	a.Merge(b)
+	Must(t, a.Len() == n*2)
+	Must(t, b.Len() == n)
+	iter := a.NewIterator(nil)
+	var prev Item
+	for iter.Next() {
+		Must(t, prev == nil || !iter.Item().Less(prev))
+		v := int(iter.Item().(Int))
+		Must(t, v%2 == 0 && v < n*2 || v%3 == 0 && v < n*3)
+		prev = iter.Item()
+	}
+	for i := 0; i < n; i++ {
+		Must(t, b.Has(Int(i*3)))
+	}
+	// Merge into itself.
+	b.Merge(b)
+	Must(t, b.Len() == n*2)
+	// Merge an empty one.
+	a.Merge(New(8))
+	Must(t, a.Len() == n*2)
+}
+
+// intSlice returns the items of a skiplist of Ints.
+func intSlice(sl *SkipList) (values []int) {
+	iter := sl.NewIterator(nil)
+	for iter.Next() {
+		values = append(values, int(iter.Item().(Int)))
+	}
+	return
+}
+
+func TestDifference(t *testing.T) {
+	a, b := New(8), New(8)
+	inB := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		a.PutIfAbsent(Int(rand.Intn(300)))
+		if v := rand.Intn(300); !b.Has(Int(v)) {
+			b.Put(Int(v))
+			inB[v] = true
+		}
+	}
+	r := Difference(a, b)
+	Must(t, r.Validate() == nil)
+	Must(t, r.Len() == a.Len()-IntersectCount(a, b))
+	r.ForEach(func(item Item) bool {
+		Must(t, a.Has(item) && !inB[int(item.(Int))])
+		return true
+	})
+	// Disjoint
+	c := New(8)
+	for i := 0; i < 20; i++ {
+		c.Put(Int(1000 + i))
+	}
+	Must(t, Difference(a, c).Equal(a))
+	Must(t, Difference(a, New(8)).Equal(a))
+	Must(t, Difference(a, a).Len() == 0)
+	Must(t, Difference(New(8), a).Len() == 0)
+}
+
+func TestIntersectCount(t *testing.T) {
+	a, b, c := New(8), New(8), New(8)
+	for i := 0; i < 20; i++ {
+		a.Put(Int(i * 2))
+		b.Put(Int(i * 3))
+		c.Put(Int(i*2 + 1))
+	}
+	Must(t, IntersectCount(a, b) == 7)
+	Must(t, IntersectCount(a, b) == Intersection(a, b).Len())
+	Must(t, IntersectCount(a, c) == 0)
+	Must(t, IntersectCount(a, a.Clone()) == 20)
+	Must(t, IntersectCount(a, New(8)) == 0)
+	Must(t, a.Len() == 20 && b.Len() == 20)
+}
+
+func TestIntersectionUnion(t *testing.T) {
+	a, b, empty := New(8), New(8), New(8)
+	for i := 0; i < 20; i++ {
+		a.Put(Int(i * 2))
+		b.Put(Int(i * 3))
+	}
+	// Overlapping
+	r := Intersection(a, b)
+	Must(t, r.Len() == 7)
+	for i, v := range intSlice(r) {
+		Must(t, v == i*6)
+	}
+	Must(t, r.Validate() == nil)
+	r = Union(a, b)
+	Must(t, r.Validate() == nil)
+	Must(t, r.Len() == 33)
+	values := intSlice(r)
+	for i, v := range values {
+		Must(t, v%2 == 0 || v%3 == 0)
+		Must(t, i == 0 || values[i-1] < v)
+		Must(t, r.Rank(Int(v)) == i)
+	}
+	Must(t, a.Len() == 20 && b.Len() == 20)
+	// Disjoint
+	c := New(8)
+	for i := 0; i < 20; i++ {
+		c.Put(Int(i*2 + 1))
+	}
+	Must(t, Intersection(a, c).Len() == 0)
+	r = Union(a, c)
+	Must(t, r.Len() == 40)
+	for i, v := range intSlice(r) {
+		Must(t, v == i)
+	}
+	// Empty
+	Must(t, Intersection(a, empty).Len() == 0)
+	Must(t, Intersection(empty, a).Len() == 0)
+	Must(t, Union(a, empty).Len() == 20)
+	Must(t, Union(empty, a).Len() == 20)
+	Must(t, Union(empty, empty).Len() == 0)
+}
+
+func TestIteratorNil(t *testing.T) {
 	sl := New(7)
 	n := 1024
 	for i := n - 1; i >= 0; i-- {
@@ -164,12 +1842,1015 @@ func TestIteratorStart(t *testing.T) {
 	Must(t, i == n-start)
 }
 
+func TestIteratorSeek(t *testing.T) {
+	sl := New(7)
+	iter := sl.NewIterator(nil)
+	iter.Seek(Int(3))
+	Must(t, !iter.Next())
+	n := 1024
+	for i := n - 1; i >= 0; i-- {
+		sl.Put(Int(i * 2))
+	}
+	iter = sl.NewIterator(nil)
+	// Seek forward, up to the last item n*2-2
+	for k := 0; k < n*2-1; k += 1 + rand.Intn(64) {
+		iter.Seek(Int(k))
+		Must(t, iter.Next())
+		Must(t, iter.Item() == Int((k+1)/2*2))
+	}
+	// Seek a present key from itself
+	iter.Seek(Int(100))
+	Must(t, iter.Next() && iter.Item() == Int(100))
+	iter.Seek(Int(100))
+	Must(t, iter.Next() && iter.Item() == Int(100))
+	// Seek backward restarts from head
+	iter.Seek(Int(11))
+	Must(t, iter.Next() && iter.Item() == Int(12))
+	Must(t, iter.Next() && iter.Item() == Int(14))
+	// Seek to the end
+	iter.Seek(Int(n * 2))
+	Must(t, !iter.Next())
+	iter.Seek(Int(0))
+	Must(t, iter.Next() && iter.Item() == Int(0))
+	// Seek within a range iterator
+	iter = sl.NewRangeIterator(Int(10), Int(20))
+	iter.Seek(Int(15))
+	Must(t, iter.Next() && iter.Item() == Int(16))
+	iter.Seek(Int(19))
+	Must(t, !iter.Next())
+}
+
+func TestIteratorReset(t *testing.T) {
+	sl := New(7)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(Int(rand.Intn(n)))
+	}
+	iter := sl.NewRangeIterator(Int(10), Int(20))
+	for k := 0; k < 16; k++ {
+		var start Item
+		if k > 0 {
+			start = Int(rand.Intn(n))
+		}
+		for i := rand.Intn(8); i > 0 && iter.Next(); i-- {
+		}
+		iter.Reset(start)
+		fresh := sl.NewIterator(start)
+		for fresh.Next() {
+			Must(t, iter.Next())
+			Must(t, iter.Item() == fresh.Item())
+		}
+		Must(t, !iter.Next())
+	}
+}
+
+func TestIteratorRemaining(t *testing.T) {
+	sl := New(7)
+	Must(t, sl.NewIterator(nil).Remaining() == 0)
+	n := 1024
+	for i := n - 1; i >= 0; i-- {
+		sl.Put(Int(i))
+	}
+	iter := sl.NewIterator(nil)
+	for i := n; i >= 0; i-- {
+		Must(t, iter.Remaining() == i)
+		iter.Next()
+	}
+	Must(t, iter.Remaining() == 0)
+	start := rand.Intn(n)
+	iter = sl.NewIterator(Int(start))
+	Must(t, iter.Remaining() == n-start)
+	iter.Seek(Int(start + 10))
+	Must(t, iter.Remaining() == n-start-10 || start+10 > n)
+	// Bounded
+	iter = sl.NewRangeIterator(Int(10), Int(20))
+	for i := 10; i >= 0; i-- {
+		Must(t, iter.Remaining() == i)
+		iter.Next()
+	}
+	// Backward
+	iter = sl.NewReverseIterator(nil)
+	for i := 0; iter.Prev(); i++ {
+		Must(t, iter.Remaining() == i)
+	}
+	iter = sl.NewReverseIterator(Int(100))
+	Must(t, iter.Remaining() == n-101)
+	Must(t, iter.Prev())
+	Must(t, iter.Remaining() == n-100)
+}
+
+func TestRangeIterator(t *testing.T) {
+	collect := func(iter *Iterator) (items []Item) {
+		for iter.Next() {
+			items = append(items, iter.Item())
+		}
+		return
+	}
+	sl := New(7)
+	// Empty list
+	Must(t, len(collect(sl.NewRangeIterator(nil, nil))) == 0)
+	Must(t, len(collect(sl.NewRangeIterator(Int(1), Int(5)))) == 0)
+	n := 100
+	for i := n - 1; i >= 0; i-- {
+		sl.Put(Int(i * 2))
+	}
+	// Both bounds
+	items := collect(sl.NewRangeIterator(Int(10), Int(20)))
+	Must(t, len(items) == 5)
+	for i, item := range items {
+		Must(t, Int(10+i*2) == item)
+	}
+	items = collect(sl.NewRangeIterator(Int(9), Int(21)))
+	Must(t, len(items) == 6)
+	Must(t, Int(10) == items[0] && Int(20) == items[5])
+	// Open bounds
+	Must(t, len(collect(sl.NewRangeIterator(nil, nil))) == n)
+	Must(t, len(collect(sl.NewRangeIterator(nil, Int(10)))) == 5)
+	Must(t, len(collect(sl.NewRangeIterator(Int(190), nil))) == 5)
+	// start == stop
+	Must(t, len(collect(sl.NewRangeIterator(Int(10), Int(10)))) == 0)
+	// Excludes every element
+	Must(t, len(collect(sl.NewRangeIterator(Int(11), Int(12)))) == 0)
+	Must(t, len(collect(sl.NewRangeIterator(Int(2*n), nil))) == 0)
+	Must(t, len(collect(sl.NewRangeIterator(nil, Int(0)))) == 0)
+	Must(t, len(collect(sl.NewRangeIterator(Int(20), Int(10)))) == 0)
+}
+
+func TestLevelIterator(t *testing.T) {
+	sl := New(16)
+	n := 4096
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	for level := 0; level < sl.Level(); level++ {
+		var items []Item
+		iter := sl.NewLevelIterator(level)
+		for iter.Next() {
+			Must(t, iter.Level() > level)
+			Must(t, sl.Has(iter.Item()))
+			Must(t, sl.Rank(iter.Item()) == iter.pos-1)
+			Must(t, len(items) == 0 || items[len(items)-1].Less(iter.Item()))
+			items = append(items, iter.Item())
+		}
+		Must(t, len(items) == sl.LevelLen(level))
+		// Backward through the same ones.
+		for i := len(items) - 1; i >= 0; i-- {
+			Must(t, iter.Prev() && iter.Item() == items[i])
+		}
+		Must(t, !iter.Prev())
+	}
+	// Next goes on from an item not on the level.
+	iter := sl.NewLevelIterator(2)
+	iter.Seek(Int(n / 2))
+	for iter.Next() {
+		Must(t, iter.Level() > 2 && !iter.Item().Less(Int(n/2)))
+		Must(t, sl.Rank(iter.Item()) == iter.pos-1)
+	}
+	// Reset goes through all the items again.
+	iter.Reset(nil)
+	count := 0
+	for iter.Next() {
+		count++
+	}
+	Must(t, count == n)
+	func() {
+		defer func() { Must(t, recover() != nil) }()
+		sl.NewLevelIterator(16)
+	}()
+}
+
+func TestRangeIteratorDesc(t *testing.T) {
+	collect := func(iter *Iterator) (items []Item) {
+		for iter.Prev() {
+			items = append(items, iter.Item())
+		}
+		return
+	}
+	sl := New(7)
+	Must(t, len(collect(sl.NewRangeIteratorDesc(nil, nil))) == 0)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i * 2))
+	}
+	for _, r := range [][2]Item{{Int(10), Int(20)}, {Int(9), Int(21)}, {nil, Int(10)},
+		{Int(190), nil}, {nil, nil}, {Int(10), Int(10)}, {Int(11), Int(12)}, {Int(20), Int(10)}} {
+		var asc []Item
+		iter := sl.NewRangeIterator(r[0], r[1])
+		for iter.Next() {
+			asc = append(asc, iter.Item())
+		}
+		desc := collect(sl.NewRangeIteratorDesc(r[0], r[1]))
+		Must(t, len(desc) == len(asc))
+		for i, item := range desc {
+			Must(t, item == asc[len(asc)-1-i])
+		}
+	}
+	iter := sl.NewRangeIteratorDesc(Int(10), Int(14))
+	Must(t, iter.Prev() && iter.Item() == Int(12) && iter.Prev() && iter.Item() == Int(10))
+	Must(t, !iter.Prev() && !iter.Valid() && !iter.Prev())
+}
+
+func TestReverseIterator(t *testing.T) {
+	sl := New(7)
+	iter := sl.NewReverseIterator(nil)
+	Must(t, !iter.Prev())
+	n := 1024
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	for i := 0; i < n; i += 2 {
+		sl.Delete(Int(i))
+	}
+	iter = sl.NewReverseIterator(nil)
+	i := n - 1
+	for iter.Prev() {
+		// Must strictly descend
+		Must(t, Int(i) == iter.Item())
+		i -= 2
+	}
+	Must(t, i == -1)
+	Must(t, !iter.Prev())
+	// Go back forward.
+	Must(t, iter.Next())
+	Must(t, Int(1) == iter.Item())
+}
+
+func TestReverseIteratorStart(t *testing.T) {
+	sl := New(7)
+	n := 1024
+	for i := n - 1; i >= 0; i-- {
+		sl.Put(Int(i))
+	}
+	start := rand.Intn(n)
+	iter := sl.NewReverseIterator(Int(start))
+	i := start - 1
+	for iter.Prev() {
+		// Must equal
+		Must(t, Int(i) == iter.Item())
+		i--
+	}
+	Must(t, i == -1)
+	iter = sl.NewReverseIterator(Int(n))
+	Must(t, iter.Prev())
+	Must(t, Int(n-1) == iter.Item())
+}
+
+func TestIteratorValid(t *testing.T) {
+	sl := New(7)
+	iter := sl.NewIterator(nil)
+	Must(t, !iter.Valid())
+	Must(t, !iter.Next() && !iter.Valid())
+	n := 10
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	iter = sl.NewIterator(nil)
+	Must(t, !iter.Valid())
+	i := 0
+	for iter.Next(); iter.Valid(); iter.Next() {
+		Must(t, iter.Item() == Int(i))
+		i++
+	}
+	Must(t, i == n && !iter.Valid())
+	// Backward
+	iter = sl.NewReverseIterator(nil)
+	Must(t, !iter.Valid())
+	for iter.Prev() {
+		Must(t, iter.Valid())
+	}
+	Must(t, !iter.Valid())
+}
+
+func TestIteratorLevel(t *testing.T) {
+	sl := New(7)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	// Count the appearances in Print.
+	var buf bytes.Buffer
+	sl.Print(&buf)
+	levels := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		for _, s := range strings.Split(line[strings.Index(line, " ")+1:], " -> ") {
+			levels[s]++
+		}
+	}
+	iter := sl.NewIterator(nil)
+	Must(t, iter.Level() == 0)
+	for iter.Next() {
+		Must(t, iter.Level() == levels[fmt.Sprint(iter.Item())])
+	}
+	Must(t, iter.Level() == 0)
+}
+
+func TestIteratorRemove(t *testing.T) {
+	sl := New(7)
+	n := 1024
+	for i := n - 1; i >= 0; i-- {
+		sl.Put(Int(i))
+	}
+	iter := sl.NewIterator(nil)
+	iter.Remove() // On begin
+	for iter.Next() {
+		if iter.Item().(Int)%2 == 0 {
+			iter.Remove()
+		}
+	}
+	iter.Remove() // On end
+	Must(t, sl.Len() == n/2)
+	Must(t, sl.Validate() == nil)
+	i := 1
+	sl.ForEach(func(item Item) bool {
+		Must(t, Int(i) == item)
+		i += 2
+		return true
+	})
+	Must(t, i == n+1)
+	// Duplicates, remove the second of three.
+	sl = New(7)
+	a, b, c := scoreItem{1, "a"}, scoreItem{1, "b"}, scoreItem{1, "c"}
+	sl.PutAllowDup(a)
+	sl.PutAllowDup(b)
+	sl.PutAllowDup(c)
+	iter = sl.NewIterator(nil)
+	Must(t, iter.Next() && iter.Next() && iter.Item() == b)
+	iter.Remove()
+	Must(t, iter.Item() == a)
+	Must(t, iter.Next() && iter.Item() == c)
+	Must(t, !iter.Next())
+	Must(t, sl.Len() == 2 && sl.First() == a)
+	Must(t, sl.Validate() == nil)
+}
+
+func TestReverseIteratorFrom(t *testing.T) {
+	sl := New(7)
+	Must(t, !sl.NewReverseIteratorFrom(Int(1)).Prev())
+	n := 1024
+	for i := 0; i < n; i += 2 {
+		sl.Put(Int(i))
+	}
+	// Start matches an item.
+	iter := sl.NewReverseIteratorFrom(Int(500))
+	i := 500
+	for iter.Prev() {
+		Must(t, Int(i) == iter.Item())
+		i -= 2
+	}
+	Must(t, i == -2)
+	// Start falls between items.
+	iter = sl.NewReverseIteratorFrom(Int(501))
+	Must(t, iter.Prev())
+	Must(t, Int(500) == iter.Item())
+	Must(t, iter.Next())
+	Must(t, Int(502) == iter.Item())
+	// Start out of the bounds.
+	Must(t, !sl.NewReverseIteratorFrom(Int(-1)).Prev())
+	iter = sl.NewReverseIteratorFrom(Int(n))
+	Must(t, iter.Prev())
+	Must(t, Int(n-2) == iter.Item())
+	iter = sl.NewReverseIteratorFrom(nil)
+	Must(t, iter.Prev())
+	Must(t, Int(n-2) == iter.Item())
+}
+
+func TestForEach(t *testing.T) {
+	sl := New(7)
+	sl.ForEach(func(item Item) bool {
+		t.Errorf("unexpected item %v", item)
+		return true
+	})
+	n := 1024
+	for i := n - 1; i >= 0; i-- {
+		sl.Put(Int(i))
+	}
+	i := 0
+	sl.ForEach(func(item Item) bool {
+		Must(t, Int(i) == item)
+		i++
+		return true
+	})
+	Must(t, i == n)
+	// Early termination
+	i = 0
+	sl.ForEach(func(item Item) bool {
+		i++
+		return i < 10
+	})
+	Must(t, i == 10)
+	start := rand.Intn(n)
+	i = start
+	sl.ForEachFrom(Int(start), func(item Item) bool {
+		Must(t, Int(i) == item)
+		i++
+		return true
+	})
+	Must(t, i == n)
+	sl.ForEachFrom(Int(n), func(item Item) bool {
+		t.Errorf("unexpected item %v", item)
+		return true
+	})
+}
+
+func TestCountFunc(t *testing.T) {
+	sl := New(7)
+	negative := func(item Item) bool { return item.(Int) < 0 }
+	Must(t, sl.CountFunc(negative) == 0)
+	want := 0
+	for i := 0; i < 1000; i++ {
+		v := rand.Intn(200) - 100
+		if v < 0 {
+			want++
+		}
+		sl.PutAllowDup(Int(v))
+	}
+	Must(t, sl.CountFunc(negative) == want)
+	Must(t, sl.CountFunc(func(Item) bool { return true }) == sl.Len())
+}
+
+func TestRangeFunc(t *testing.T) {
+	sl := New(7)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	collect := func(start, stop Item, limit int) []int {
+		var items []int
+		sl.RangeFunc(start, stop, func(item Item) bool {
+			items = append(items, int(item.(Int)))
+			return len(items) < limit
+		})
+		return items
+	}
+	items := collect(Int(10), Int(20), n)
+	Must(t, len(items) == 10 && items[0] == 10 && items[9] == 19)
+	// Stopped by f
+	items = collect(Int(10), Int(20), 3)
+	Must(t, len(items) == 3 && items[2] == 12)
+	// Open bounds
+	Must(t, len(collect(nil, Int(5), n)) == 5)
+	Must(t, len(collect(Int(95), nil, n)) == 5)
+	Must(t, len(collect(nil, nil, n)) == n)
+	// Empty ranges
+	Must(t, len(collect(Int(20), Int(20), n)) == 0)
+	Must(t, len(collect(Int(30), Int(20), n)) == 0)
+	Must(t, len(collect(Int(n), nil, n)) == 0)
+}
+
+func TestValues(t *testing.T) {
+	sl := New(7)
+	Must(t, len(sl.Values()) == 0)
+	n := 100
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	items := sl.Values()
+	Must(t, len(items) == n && cap(items) == n)
+	values, err := sl.IntSlice()
+	Must(t, err == nil)
+	Must(t, len(values) == n && cap(values) == n)
+	for i := 0; i < n; i++ {
+		Must(t, items[i] == Int(i))
+		Must(t, values[i] == i)
+	}
+	sl = New(7)
+	sl.Put(scoreItem{1, "a"})
+	values, err = sl.IntSlice()
+	Must(t, values == nil && err != nil)
+}
+
+func TestGetN(t *testing.T) {
+	sl := New(7)
+	Must(t, len(sl.GetN(nil, 10)) == 0)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i * 2))
+	}
+	items := sl.GetN(Int(51), 10)
+	Must(t, len(items) == 10)
+	for i, item := range items {
+		Must(t, item == Int(52+i*2))
+	}
+	items = sl.GetN(nil, 3)
+	Must(t, len(items) == 3 && items[0] == Int(0) && items[2] == Int(4))
+	// More than remaining
+	items = sl.GetN(Int(190), 10)
+	Must(t, len(items) == 5 && items[4] == Int(198))
+	Must(t, len(sl.GetN(Int(n*2), 10)) == 0)
+	Must(t, len(sl.GetN(nil, 0)) == 0)
+	Must(t, len(sl.GetN(nil, n+1)) == n)
+}
+
+func TestGetNInto(t *testing.T) {
+	sl := New(7)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i * 2))
+	}
+	buf := make([]Item, 0, 64)
+	items := sl.GetNInto(Int(51), buf[:0])
+	Must(t, len(items) == 64 && &items[0] == &buf[:1][0])
+	for i, item := range items {
+		Must(t, item == Int(52+i*2))
+	}
+	// Appends behind the existing ones.
+	items = sl.GetNInto(Int(190), append(buf[:0], Int(-1)))
+	Must(t, len(items) == 6 && items[0] == Int(-1) && items[5] == Int(198))
+	Must(t, len(sl.GetNInto(nil, buf[:64])) == 64)
+	Must(t, sl.GetNInto(nil, nil) == nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = sl.GetNInto(nil, buf[:0])
+	})
+	Must(t, allocs == 0)
+}
+
+func TestRangeContext(t *testing.T) {
+	sl := New(16)
+	n := 10000
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	count := 0
+	f := func(item Item) error {
+		Must(t, item == Int(count))
+		count++
+		return nil
+	}
+	Must(t, sl.RangeContext(context.Background(), nil, nil, f) == nil)
+	Must(t, count == n)
+	count = 100
+	Must(t, sl.RangeContext(context.Background(), Int(100), Int(200), f) == nil)
+	Must(t, count == 200)
+	// Canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	count = 0
+	err := sl.RangeContext(ctx, nil, nil, func(item Item) error {
+		if count++; count == 1000 {
+			cancel()
+		}
+		return nil
+	})
+	Must(t, err == context.Canceled)
+	Must(t, count >= 1000 && count <= 1000+rangeCheckEvery)
+	Must(t, sl.RangeContext(ctx, nil, nil, f) == context.Canceled)
+	// Error by f
+	errStop := fmt.Errorf("stop")
+	count = 0
+	err = sl.RangeContext(context.Background(), nil, nil, func(item Item) error {
+		if count++; count == 10 {
+			return errStop
+		}
+		return nil
+	})
+	Must(t, err == errStop && count == 10)
+}
+
+func TestStream(t *testing.T) {
+	sl := New(7)
+	_, ok := <-sl.Stream(context.Background())
+	Must(t, !ok)
+	n := 100
+	for _, i := range rand.Perm(n) {
+		sl.Put(Int(i))
+	}
+	i := 0
+	for item := range sl.Stream(context.Background()) {
+		Must(t, item == Int(i))
+		i++
+	}
+	Must(t, i == n)
+	// Stop early
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := sl.Stream(ctx)
+	Must(t, <-ch == Int(0))
+	cancel()
+	for range ch {
+	}
+}
+
+func TestPeek(t *testing.T) {
+	sl := New(7)
+	Must(t, len(sl.Peek(3)) == 0)
+	for _, i := range rand.Perm(100) {
+		sl.Put(Int(i))
+	}
+	items := sl.Peek(10)
+	Must(t, len(items) == 10 && sl.Len() == 100)
+	c := sl.Clone()
+	for _, item := range items {
+		Must(t, c.PopFirst() == item)
+	}
+	Must(t, len(sl.Peek(0)) == 0)
+	Must(t, len(sl.Peek(200)) == 100)
+	Must(t, sl.Len() == 100)
+}
+
+func TestSample(t *testing.T) {
+	sl := New(7)
+	first, last := sl.Sample(3, 3)
+	Must(t, len(first) == 0 && len(last) == 0)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	first, last = sl.Sample(3, 2)
+	Must(t, len(first) == 3 && first[0] == Int(0) && first[2] == Int(2))
+	Must(t, len(last) == 2 && last[0] == Int(n-2) && last[1] == Int(n-1))
+	first, last = sl.Sample(0, 0)
+	Must(t, len(first) == 0 && len(last) == 0)
+	// Overlapped
+	first, last = sl.Sample(60, 60)
+	Must(t, len(first) == 60 && first[59] == Int(59))
+	Must(t, len(last) == 60 && last[0] == Int(40))
+	first, last = sl.Sample(n+1, n+1)
+	Must(t, len(first) == n && len(last) == n)
+	Must(t, first[n-1] == Int(n-1) && last[0] == Int(0))
+}
+
+func TestSnapshot(t *testing.T) {
+	sl := New(7)
+	n := 100
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	items := sl.Snapshot()
+	for i, item := range items {
+		Must(t, item == Int(i))
+		sl.Delete(item)
+		sl.Put(Int(n + i))
+	}
+	Must(t, sl.Len() == n && sl.First() == Int(n))
+}
+
+func TestStats(t *testing.T) {
+	sl := New(12)
+	stats := sl.Stats()
+	Must(t, stats.Length == 0 && stats.Level == 0 && len(stats.PerLevel) == 0)
+	n := 1024
+	for i := 0; i < n; i++ {
+		sl.Put(Int(rand.Int()))
+	}
+	stats = sl.Stats()
+	Must(t, stats.Length == n)
+	Must(t, stats.Level == sl.Level())
+	Must(t, stats.MaxLevel == 12)
+	Must(t, len(stats.PerLevel) == sl.Level())
+	Must(t, stats.PerLevel[0] == sl.Len())
+	for i := 1; i < stats.Level; i++ {
+		Must(t, stats.PerLevel[i] <= stats.PerLevel[i-1])
+	}
+	Must(t, stats.PerLevel[stats.Level-1] > 0)
+}
+
+func TestResize(t *testing.T) {
+	sl := New(2)
+	n := 1 << 12
+	for i := 0; i < n; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.Level() == 2)
+	Must(t, sl.Resize(1) != nil)
+	Must(t, sl.Resize(16) == nil)
+	Must(t, sl.MaxLevel() == 16)
+	for i := 0; i < n; i++ {
+		sl.Put(Int(n + i))
+	}
+	Must(t, sl.Level() > 2)
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.Len() == 2*n)
+	// Shrink
+	Must(t, sl.Resize(sl.Level()-1) != nil)
+	Must(t, sl.Resize(sl.Level()) == nil)
+	Must(t, sl.MaxLevel() == sl.Level())
+	sl.Put(Int(-1))
+	Must(t, sl.Validate() == nil)
+	Must(t, sl.First() == Int(-1))
+}
+
+func TestLevelLen(t *testing.T) {
+	sl := New(16)
+	Must(t, sl.LevelLen(0) == 0)
+	for i := 0; i < 1024; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.LevelLen(0) == sl.Len())
+	stats := sl.Stats()
+	for i := 0; i < sl.Level(); i++ {
+		Must(t, sl.LevelLen(i) == stats.PerLevel[i])
+	}
+	Must(t, sl.LevelLen(-1) == 0)
+	Must(t, sl.LevelLen(sl.Level()) == 0)
+	Must(t, sl.LevelLen(sl.MaxLevel()) == 0)
+}
+
+func TestMemEstimate(t *testing.T) {
+	sl := New(16)
+	size := sl.MemEstimate()
+	Must(t, size > 0)
+	for i := 0; i < 1024; i++ {
+		sl.Put(Int(i))
+		Must(t, sl.MemEstimate() > size)
+		size = sl.MemEstimate()
+	}
+	// At least a node and a level per item.
+	Must(t, size > 1024*(int(unsafe.Sizeof(node{}))+int(unsafe.Sizeof(nodeLevel{}))))
+	sl.Clear()
+	Must(t, sl.MemEstimate() < size)
+}
+
+func TestMaxGap(t *testing.T) {
+	towers := map[Item]bool{Int(0): true, Int(3): true, Int(9): true}
+	levelOf := func(item Item) int {
+		if towers[item] {
+			return 2
+		}
+		return 1
+	}
+	sl := New(4, WithLevelOf(levelOf))
+	Must(t, sl.MaxGap() == 0)
+	sl.Put(Int(1))
+	sl.Put(Int(2))
+	Must(t, sl.MaxGap() == 2)
+	for i := 0; i < 10; i++ {
+		sl.PutIfAbsent(Int(i))
+	}
+	// 4, 5, 6, 7, 8 between 3 and 9
+	Must(t, sl.MaxGap() == 5)
+	// After the last tower
+	for i := 10; i < 20; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.MaxGap() == 10)
+}
+
+func TestMeanLevel(t *testing.T) {
+	sl := NewWithOptions(32, 0.5, 1)
+	Must(t, sl.MeanLevel() == 0)
+	for i := 0; i < 100000; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, math.Abs(sl.MeanLevel()-2.0) < 0.05)
+	sl = NewWithOptions(32, 0.25, 1)
+	for i := 0; i < 100000; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, math.Abs(sl.MeanLevel()-4.0/3) < 0.05)
+}
+
+func TestRandLevel(t *testing.T) {
+	n := 1 << 18
+	for _, factorP := range []float64{0.5, 0.25} {
+		// Buffered bits and a plain RandSource.
+		for _, sl := range []*SkipList{
+			NewWithOptions(32, factorP, 1),
+			New(32, WithRandSource(struct{ RandSource }{rand.New(rand.NewSource(1))})),
+		} {
+			sl.factorP = factorP
+			counts := make([]int, 33)
+			for i := 0; i < n; i++ {
+				counts[sl.randLevel()]++
+			}
+			// P(level = k) = factorP^(k-1) * (1-factorP)
+			want := float64(n) * (1 - factorP)
+			for k := 1; k <= 6; k++ {
+				Must(t, math.Abs(float64(counts[k])-want) < 5*math.Sqrt(want))
+				want *= factorP
+			}
+		}
+	}
+	// Capped at maxLevel.
+	sl := NewWithOptions(2, 0.5, 1)
+	for i := 0; i < 1000; i++ {
+		Must(t, sl.randLevel() <= 2)
+	}
+}
+
+func TestWalkNodes(t *testing.T) {
+	sl := New(8)
+	for _, i := range rand.Perm(1000) {
+		sl.Put(Int(i))
+	}
+	levels := make(map[Item]int)
+	var prev Item
+	sl.WalkNodes(func(item Item, level int) {
+		Must(t, prev == nil || prev.Less(item))
+		Must(t, level >= 1 && level <= sl.Level())
+		levels[item] = level
+		prev = item
+	})
+	Must(t, len(levels) == sl.Len())
+	// Rebuild the same layout.
+	c := New(8, WithLevelOf(func(item Item) int { return levels[item] }))
+	sl.ForEach(func(item Item) bool {
+		c.PutAppend(item)
+		return true
+	})
+	var a, b bytes.Buffer
+	sl.Print(&a)
+	c.Print(&b)
+	Must(t, a.String() == b.String())
+}
+
+func TestValidate(t *testing.T) {
+	sl := NewWithRandSeed(16, 1)
+	Must(t, sl.Validate() == nil)
+	n := 1024
+	for i := 0; i < n*2; i++ {
+		sl.Put(Int(rand.Intn(n)))
+		if i%3 == 0 {
+			sl.PutAllowDup(Int(rand.Intn(n)))
+		}
+		if i%5 == 0 {
+			sl.Delete(Int(rand.Intn(n)))
+		}
+	}
+	sl.PopFirst()
+	sl.PopLast()
+	Must(t, sl.Validate() == nil)
+	// Corrupt it in different ways.
+	corrupt := []func(sl *SkipList){
+		func(sl *SkipList) { sl.length++ },
+		func(sl *SkipList) { sl.head.levels[0].forward.item = Int(n) },
+		func(sl *SkipList) { sl.head.levels[0].forward.backward = nil },
+		func(sl *SkipList) { sl.head.levels[sl.level-1].span++ },
+		func(sl *SkipList) {
+			// Head linked above the level.
+			if sl.level == sl.maxLevel {
+				sl.level--
+				return
+			}
+			sl.head.levels[sl.level].forward = sl.head.levels[0].forward
+		},
+		func(sl *SkipList) {
+			x := sl.head.levels[1].forward
+			sl.head.levels[0].forward = x.levels[0].forward
+		},
+		func(sl *SkipList) {
+			// Out of level 1, but still at level 2.
+			x, p := sl.head.levels[2].forward, sl.head
+			for p.levels[1].forward != x {
+				p = p.levels[1].forward
+			}
+			p.levels[1].span += x.levels[1].span
+			p.levels[1].forward = x.levels[1].forward
+		},
+		func(sl *SkipList) { sl.level = 0 },
+	}
+	for _, f := range corrupt {
+		c := sl.Clone()
+		Must(t, c.Validate() == nil)
+		f(c)
+		Must(t, c.Validate() != nil)
+	}
+}
+
+func TestSkipListString(t *testing.T) {
+	sl := New(16, WithRandSource(&seqSource{seq: []int{0, 0, 0xffff}}))
+	Must(t, sl.String() == "SkipList(len=0, level=0/16)")
+	for i := 0; i < 42; i++ {
+		sl.Put(Int(i))
+	}
+	Must(t, sl.String() == "SkipList(len=42, level=3/16)")
+	Must(t, fmt.Sprintf("%v", sl) == sl.String())
+}
+
+func TestPrintLevel(t *testing.T) {
+	// Levels: 1, 2, 3, 1, 2, 3, ...
+	up, stay := 0, 0xffff
+	sl := New(8, WithRandSource(&seqSource{seq: []int{stay, up, stay, up, up, stay}}))
+	for i := 1; i <= 6; i++ {
+		sl.Put(Int(i))
+	}
+	var buf bytes.Buffer
+	Must(t, sl.PrintLevel(&buf, 0) == nil)
+	Must(t, buf.String() == "Level[0]: 1 -> 2 -> 3 -> 4 -> 5 -> 6 -> nil\n")
+	buf.Reset()
+	Must(t, sl.PrintLevel(&buf, 2) == nil)
+	Must(t, buf.String() == "Level[2]: 3 -> 6 -> nil\n")
+	buf.Reset()
+	Must(t, sl.PrintLevel(&buf, 3) != nil)
+	Must(t, sl.PrintLevel(&buf, -1) != nil)
+	Must(t, buf.Len() == 0)
+	sl.Print(&buf)
+	Must(t, buf.String() == "Level[0]: 1 -> 2 -> 3 -> 4 -> 5 -> 6 -> nil\n"+
+		"Level[1]: 2 -> 3 -> 5 -> 6 -> nil\n"+
+		"Level[2]: 3 -> 6 -> nil\n")
+}
+
+func TestPrintVerbose(t *testing.T) {
+	// Levels: 1, 2, 3, 1, 2, 3, ...
+	up, stay := 0, 0xffff
+	sl := New(8, WithRandSource(&seqSource{seq: []int{stay, up, stay, up, up, stay}}))
+	for i := 1; i <= 6; i++ {
+		sl.Put(Int(i))
+	}
+	var buf bytes.Buffer
+	sl.PrintVerbose(&buf)
+	Must(t, buf.String() == "Level[0]: head -(1)-> 1 -(1)-> 2 -(1)-> 3 -(1)-> 4 -(1)-> 5 -(1)-> 6 -> nil\n"+
+		"Level[1]: head -(2)-> 2 -(1)-> 3 -(2)-> 5 -(1)-> 6 -> nil\n"+
+		"Level[2]: head -(3)-> 3 -(3)-> 6 -> nil\n")
+}
+
 // The maxLevel masters the bench results.
 func BenchmarkPut(b *testing.B) {
 	sl := New(50)
 	for i := 0; i < b.N; i++ {
 		sl.Put(Int(i))
 	}
+	b.StopTimer()
+	b.ReportMetric(sl.MeanLevel(), "levels/node")
+}
+
+func BenchmarkRandLevel(b *testing.B) {
+	sl := New(50)
+	for i := 0; i < b.N; i++ {
+		sl.randLevel()
+	}
+}
+
+func BenchmarkRandLevelIntn(b *testing.B) {
+	// Flips a coin by Intn each, without the buffered bits.
+	sl := New(50, WithRandSource(struct{ RandSource }{rand.New(rand.NewSource(1))}))
+	for i := 0; i < b.N; i++ {
+		sl.randLevel()
+	}
+}
+
+func BenchmarkPutAppend(b *testing.B) {
+	sl := New(50)
+	for i := 0; i < b.N; i++ {
+		sl.PutAppend(Int(i))
+	}
+}
+
+func BenchmarkBuildFromSorted(b *testing.B) {
+	items := make([]Item, b.N)
+	for i := range items {
+		items[i] = Int(i)
+	}
+	b.ResetTimer()
+	BuildFromSorted(50, items)
+}
+
+func BenchmarkIterator(b *testing.B) {
+	sl := New(50)
+	for i := 0; i < 1024; i++ {
+		sl.Put(Int(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iter := sl.NewIterator(nil)
+		for iter.Next() {
+			_ = iter.Item()
+		}
+	}
+}
+
+func BenchmarkForEach(b *testing.B) {
+	sl := New(50)
+	for i := 0; i < 1024; i++ {
+		sl.Put(Int(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sl.ForEach(func(item Item) bool { return true })
+	}
+}
+
+func benchmarkChurn(b *testing.B, sl *SkipList) {
+	for i := 0; i < 1024; i++ {
+		sl.Put(Int(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sl.Put(Int(i))
+		sl.Delete(Int(i))
+	}
+}
+
+func BenchmarkChurn(b *testing.B) { benchmarkChurn(b, New(50)) }
+
+func BenchmarkChurnWithPool(b *testing.B) { benchmarkChurn(b, NewWithPool(50)) }
+
+// A few items deleted and put in turn, the top levels keep getting empty.
+func benchmarkLevelChurn(b *testing.B, sl *SkipList) {
+	for i := 0; i < 4; i++ {
+		sl.Put(Int(i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sl.Delete(Int(i))
+		sl.Put(Int(i + 4))
+	}
+}
+
+func BenchmarkLevelChurn(b *testing.B) { benchmarkLevelChurn(b, New(50)) }
+
+func BenchmarkLevelChurnKeepLevel(b *testing.B) {
+	benchmarkLevelChurn(b, New(50, WithKeepLevel()))
 }
 
 func BenchmarkGet(b *testing.B) {